@@ -10,7 +10,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-const pluginIdGoFileTemplate = `package main
+const pluginIdGoFileTemplate = `package autolink
 
 var manifest = struct {
 	Id      string
@@ -100,11 +100,11 @@ func dumpPluginVersion(manifest *model.Manifest) {
 func applyManifest(manifest *model.Manifest) error {
 	if manifest.HasServer() {
 		if err := ioutil.WriteFile(
-			"server/manifest.go",
+			"server/autolink/manifest.go",
 			[]byte(fmt.Sprintf(pluginIdGoFileTemplate, manifest.Id, manifest.Version)),
 			0644,
 		); err != nil {
-			return errors.Wrap(err, "failed to write server/manifest.go")
+			return errors.Wrap(err, "failed to write server/autolink/manifest.go")
 		}
 	}
 
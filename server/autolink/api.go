@@ -0,0 +1,343 @@
+package autolink
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+const (
+	apiPrefix         = "/api/v1"
+	apiLinksPath      = apiPrefix + "/links"
+	apiValidationPath = apiPrefix + "/validate"
+)
+
+var (
+	errLinkNotFound = errors.New("link not found")
+	errLinkExists   = errors.New("a link with this name already exists")
+	errLinkNoName   = errors.New("link name is required")
+)
+
+// linkValidation is the result of validating a single link through the API.
+type linkValidation struct {
+	Name  string
+	Valid bool
+	Error string `json:",omitempty"`
+
+	// Warnings lists how the link overlaps with the other links, as if the validated links
+	// replaced the active links of the same name and the others were added after them.
+	Warnings []string `json:",omitempty"`
+}
+
+// ServeHTTP serves the link management API. Every route is restricted to system admins.
+//
+//	GET    /api/v1/links          list all links
+//	POST   /api/v1/links          create a link
+//	GET    /api/v1/links/{name}   get a link
+//	PUT    /api/v1/links/{name}   update a link
+//	DELETE /api/v1/links/{name}   delete a link
+//	POST   /api/v1/validate       validate a list of links without saving them
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		writeAPIError(w, http.StatusUnauthorized, errors.New("not authorized"))
+		return
+	}
+
+	if !p.API.HasPermissionTo(userID, model.PERMISSION_MANAGE_SYSTEM) {
+		writeAPIError(w, http.StatusForbidden, errors.New("only system administrators can manage links"))
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == apiLinksPath:
+		switch r.Method {
+		case http.MethodGet:
+			p.handleListLinks(w, r)
+		case http.MethodPost:
+			p.handleCreateLink(w, r)
+		default:
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+
+	case strings.HasPrefix(path, apiLinksPath+"/"):
+		name, err := url.PathUnescape(strings.TrimPrefix(path, apiLinksPath+"/"))
+		if err != nil || name == "" {
+			writeAPIError(w, http.StatusBadRequest, errors.New("invalid link name"))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			p.handleGetLink(w, r, name)
+		case http.MethodPut:
+			p.handleUpdateLink(w, r, name)
+		case http.MethodDelete:
+			p.handleDeleteLink(w, r, name)
+		default:
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+
+	case path == apiValidationPath:
+		if r.Method != http.MethodPost {
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		p.handleValidateLinks(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Plugin) handleListLinks(w http.ResponseWriter, r *http.Request) {
+	links := p.getConfiguration().Links
+	if links == nil {
+		links = []*Link{}
+	}
+	writeAPIResponse(w, http.StatusOK, links)
+}
+
+func (p *Plugin) handleGetLink(w http.ResponseWriter, r *http.Request, name string) {
+	links := p.getConfiguration().Links
+	i := findLink(links, name)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, links[i])
+}
+
+func (p *Plugin) handleCreateLink(w http.ResponseWriter, r *http.Request) {
+	link, err := decodeLink(r, "", p.getConfiguration())
+	if err != nil {
+		writeTranslatedAPIError(w, p.requestTranslationFunc(r), http.StatusBadRequest, err)
+		return
+	}
+
+	p.configurationLock.Lock()
+	defer p.configurationLock.Unlock()
+
+	if err := p.syncLinks(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	links := p.getConfiguration().Links
+	if findLink(links, link.Name) >= 0 {
+		writeAPIError(w, http.StatusConflict, errLinkExists)
+		return
+	}
+
+	newLinks := make([]*Link, 0, len(links)+1)
+	newLinks = append(newLinks, links...)
+	newLinks = append(newLinks, link)
+	change := &linkRevision{UserId: r.Header.Get("Mattermost-User-Id"), Change: changeCreate, Link: link.Name}
+	if err := p.saveLinks(newLinks, change); err != nil {
+		p.writeSaveLinksError(w, r, err)
+		return
+	}
+
+	writeAPIResponse(w, http.StatusCreated, link)
+}
+
+func (p *Plugin) handleUpdateLink(w http.ResponseWriter, r *http.Request, name string) {
+	link, err := decodeLink(r, name, p.getConfiguration())
+	if err != nil {
+		writeTranslatedAPIError(w, p.requestTranslationFunc(r), http.StatusBadRequest, err)
+		return
+	}
+
+	p.configurationLock.Lock()
+	defer p.configurationLock.Unlock()
+
+	if err := p.syncLinks(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	links := p.getConfiguration().Links
+	i := findLink(links, name)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	}
+	if link.Name != name && findLink(links, link.Name) >= 0 {
+		writeAPIError(w, http.StatusConflict, errLinkExists)
+		return
+	}
+
+	newLinks := make([]*Link, len(links))
+	copy(newLinks, links)
+	newLinks[i] = link
+	change := &linkRevision{UserId: r.Header.Get("Mattermost-User-Id"), Change: changeUpdate, Link: link.Name}
+	if err := p.saveLinks(newLinks, change); err != nil {
+		p.writeSaveLinksError(w, r, err)
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, link)
+}
+
+func (p *Plugin) handleDeleteLink(w http.ResponseWriter, r *http.Request, name string) {
+	p.configurationLock.Lock()
+	defer p.configurationLock.Unlock()
+
+	if err := p.syncLinks(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	links := p.getConfiguration().Links
+	i := findLink(links, name)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	}
+
+	newLinks := make([]*Link, 0, len(links)-1)
+	newLinks = append(newLinks, links[:i]...)
+	newLinks = append(newLinks, links[i+1:]...)
+	change := &linkRevision{UserId: r.Header.Get("Mattermost-User-Id"), Change: changeDelete, Link: name}
+	if err := p.saveLinks(newLinks, change); err != nil {
+		p.writeSaveLinksError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Plugin) handleValidateLinks(w http.ResponseWriter, r *http.Request) {
+	var links []*Link
+	if err := json.NewDecoder(r.Body).Decode(&links); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("request body must be a list of links"))
+		return
+	}
+
+	T := p.requestTranslationFunc(r)
+	results := make([]*linkValidation, 0, len(links))
+	validated := make(map[*AutoLinker]*linkValidation)
+	var compiled []*AutoLinker
+	for _, link := range links {
+		result := &linkValidation{Valid: true}
+		if link != nil {
+			result.Name = link.Name
+		}
+		al, err := NewAutoLinker(link, p.getConfiguration())
+		if err != nil {
+			result.Valid = false
+			result.Error = translateError(T, err)
+		} else {
+			validated[al] = result
+			compiled = append(compiled, al)
+		}
+		results = append(results, result)
+	}
+
+	for _, o := range analyzeOverlaps(withValidatedLinks(p.links.Load().([]*AutoLinker), compiled)) {
+		for _, l := range []*AutoLinker{o.Link, o.Other} {
+			if result := validated[l]; result != nil {
+				result.Warnings = append(result.Warnings, translateError(T, o.warning()))
+			}
+		}
+	}
+
+	writeAPIResponse(w, http.StatusOK, results)
+}
+
+// withValidatedLinks returns the active links with the validated ones in place of the links
+// of the same name, and the other validated links after them.
+func withValidatedLinks(active, validated []*AutoLinker) []*AutoLinker {
+	byName := make(map[string]*AutoLinker)
+	for _, l := range validated {
+		if l.link.Name != "" {
+			byName[l.link.Name] = l
+		}
+	}
+
+	used := make(map[*AutoLinker]bool)
+	links := make([]*AutoLinker, 0, len(active)+len(validated))
+	for _, l := range active {
+		if v := byName[l.link.Name]; v != nil && l.link.Name != "" {
+			l = v
+			used[v] = true
+		}
+		links = append(links, l)
+	}
+	for _, l := range validated {
+		if !used[l] {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// decodeLink reads a link from the request body and validates it against the configuration. The
+// default name is used when the body does not name the link.
+func decodeLink(r *http.Request, defaultName string, conf *Configuration) (*Link, error) {
+	var link *Link
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil || link == nil {
+		return nil, errors.New("request body must be a link")
+	}
+
+	if link.Name == "" {
+		link.Name = defaultName
+	}
+	if link.Name == "" {
+		return nil, errLinkNoName
+	}
+
+	if _, err := NewAutoLinker(link, conf); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// findLink returns the index of the link with the given name, or -1.
+func findLink(links []*Link, name string) int {
+	for i, l := range links {
+		if l != nil && l.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func writeAPIResponse(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeSaveLinksError writes an error saving the links. A change made on another node at the
+// same time is a conflict the user can resolve.
+func (p *Plugin) writeSaveLinksError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == errLinksChangedConcurrently {
+		writeTranslatedAPIError(w, p.requestTranslationFunc(r), http.StatusConflict, err)
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, err)
+}
+
+func writeAPIError(w http.ResponseWriter, statusCode int, err error) {
+	writeTranslatedAPIError(w, defaultT, statusCode, err)
+}
+
+// writeTranslatedAPIError writes the error in the language of T, for errors the user can fix
+// such as an invalid link.
+func writeTranslatedAPIError(w http.ResponseWriter, T i18n.TranslateFunc, statusCode int, err error) {
+	writeAPIResponse(w, statusCode, map[string]string{"error": translateError(T, err)})
+}
+
+// requestTranslationFunc returns the function translating messages to the locale of the user
+// making the request.
+func (p *Plugin) requestTranslationFunc(r *http.Request) i18n.TranslateFunc {
+	return p.translationFunc(r.Header.Get("Mattermost-User-Id"))
+}
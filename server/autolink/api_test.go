@@ -0,0 +1,176 @@
+package autolink
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAPITest(t *testing.T) (*Plugin, *[]*Link) {
+	links := []*Link{{
+		Name:     "mattermost",
+		Pattern:  "(Mattermost)",
+		Template: "[Mattermost](https://mattermost.com)",
+	}}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = Configuration{Links: links}
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("HasPermissionTo", "user", model.PERMISSION_MANAGE_SYSTEM).Return(false)
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Locale: "en"}, nil)
+	api.On("GetConfig").Return(func() *model.Config {
+		return &model.Config{}
+	})
+
+	var saved []*Link
+	api.On("SaveConfig", mock.AnythingOfType("*model.Config")).Return(func(config *model.Config) *model.AppError {
+		saved = config.PluginSettings.Plugins[manifest.Id]["links"].([]*Link)
+		return nil
+	})
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	return p, &saved
+}
+
+func doAPIRequest(p *Plugin, userID, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if userID != "" {
+		r.Header.Set("Mattermost-User-Id", userID)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(&plugin.Context{}, w, r)
+	return w
+}
+
+func TestAPIAuthorization(t *testing.T) {
+	p, _ := setupAPITest(t)
+
+	w := doAPIRequest(p, "", http.MethodGet, "/api/v1/links", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doAPIRequest(p, "user", http.MethodGet, "/api/v1/links", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodGet, "/api/v1/links", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodGet, "/api/v2/links", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIListAndGet(t *testing.T) {
+	p, _ := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodGet, "/api/v1/links", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var links []*Link
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&links))
+	require.Len(t, links, 1)
+	assert.Equal(t, "mattermost", links[0].Name)
+
+	w = doAPIRequest(p, "admin", http.MethodGet, "/api/v1/links/mattermost", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var link *Link
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, "(Mattermost)", link.Pattern)
+
+	w = doAPIRequest(p, "admin", http.MethodGet, "/api/v1/links/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPICreate(t *testing.T) {
+	p, saved := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `{"Name": "jira", "Pattern": "(MM)(-)(?P<jira_id>\\d+)", "Template": "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, *saved, 2)
+	assert.Equal(t, "jira", (*saved)[1].Name)
+	assert.Equal(t, "(MM)(-)(?P<jira_id>\\d+)", (*saved)[1].Pattern)
+
+	w = doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `{"Name": "mattermost", "Pattern": "(Mattermost)", "Template": "x"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `{"Pattern": "(Mattermost)", "Template": "x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `{"Name": "broken", "Pattern": "(Mattermost", "Template": "x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing closing )")
+
+	w = doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIUpdate(t *testing.T) {
+	p, saved := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodPut, "/api/v1/links/mattermost", `{"Pattern": "(Mattermost)", "Template": "[Mattermost](https://mattermost.org)"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, *saved, 1)
+	assert.Equal(t, "mattermost", (*saved)[0].Name)
+	assert.Equal(t, "[Mattermost](https://mattermost.org)", (*saved)[0].Template)
+
+	w = doAPIRequest(p, "admin", http.MethodPut, "/api/v1/links/missing", `{"Pattern": "(Mattermost)", "Template": "x"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodPut, "/api/v1/links/mattermost", `{"Pattern": "", "Template": "x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIDelete(t *testing.T) {
+	p, saved := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodDelete, "/api/v1/links/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doAPIRequest(p, "admin", http.MethodDelete, "/api/v1/links/mattermost", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Len(t, *saved, 0)
+}
+
+func TestAPIValidate(t *testing.T) {
+	p, saved := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodPost, "/api/v1/validate", `[{"Name": "ok", "Pattern": "(a)", "Template": "b"}, {"Name": "bad", "Pattern": "(a", "Template": "b"}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var results []*linkValidation
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&results))
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Valid)
+	assert.False(t, results[1].Valid)
+	assert.NotEmpty(t, results[1].Error)
+	assert.Nil(t, *saved)
+
+	w = doAPIRequest(p, "admin", http.MethodGet, "/api/v1/validate", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestAPIValidateOverlaps(t *testing.T) {
+	p, _ := setupAPITest(t)
+
+	w := doAPIRequest(p, "admin", http.MethodPost, "/api/v1/validate", `[{"Name": "mattermost-docs", "Pattern": "(Mattermost)", "Template": "[Mattermost](https://docs.mattermost.com)"}, {"Name": "mattermost", "Pattern": "(Mattermost)", "Template": "[Mattermost](https://mattermost.org)"}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var results []*linkValidation
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&results))
+	require.Len(t, results, 2)
+
+	// The second link replaces the active one of the same name, which comes first.
+	assert.True(t, results[0].Valid)
+	assert.Equal(t, []string{`"mattermost-docs" never applies, "mattermost" comes first and always links its matches, such as "Mattermost"`}, results[0].Warnings)
+	assert.Equal(t, results[0].Warnings, results[1].Warnings)
+}
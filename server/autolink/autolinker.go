@@ -0,0 +1,265 @@
+package autolink
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/utils/markdown"
+)
+
+const (
+	prefixGroup = "MMDisableNonWordPrefix"
+	suffixGroup = "DisableNonWordSuffix"
+
+	suffixPattern = "(?P<" + suffixGroup + ">$|\\s|\\.|\\!|\\?|\\,|\\))"
+)
+
+var errPatternOrTemplateEmpty = newLocalizedError("autolink.link.pattern_or_template_empty", nil)
+
+// AutoLinker helper for replace regex with links
+type AutoLinker struct {
+	link     *Link
+	matcher  Matcher
+	template string
+	lookup   *Lookup
+
+	// groupTemplates holds the resolved Templates of the link, and validators its
+	// Validators, by group name.
+	groupTemplates map[string]string
+	validators     map[string]*Validator
+
+	// templateParts holds the split template and group templates, by template.
+	templateParts map[string][]templatePart
+
+	conditions *conditions
+}
+
+// Match is a single replacement made by an AutoLinker.
+type Match struct {
+	// Start and End are the byte offsets of the replaced text. The prefix and suffix
+	// matched around it are not part of the range.
+	Start, End  int
+	Text        string
+	Replacement string
+
+	// Captures holds the named groups of the pattern that took part in the match.
+	Captures map[string]string
+
+	// Rejected is why the match is not linked, such as a capture failing a validator. Only
+	// Explain returns rejected matches.
+	Rejected string
+
+	// rejection is Rejected as an error, so that it can be translated.
+	rejection error
+
+	// expand expands a template with the groups of the match, like the link's template,
+	// passing their values through escape if it is set.
+	expand func(template string, escape func(string) string) string
+}
+
+// NewAutoLinker create and initialize a AutoLinker. The configuration supplies the limits the
+// link is checked against and may be nil to use the defaults.
+func NewAutoLinker(link *Link, conf *Configuration) (*AutoLinker, error) {
+	if link == nil || (len(link.Template) == 0 && len(link.Templates) == 0) {
+		return nil, errPatternOrTemplateEmpty
+	}
+
+	if link.WebhookURL != "" {
+		u, err := url.Parse(link.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, newLocalizedError("autolink.link.webhook_url", nil)
+		}
+	}
+
+	template, err := resolveVariables(link.Template, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var lookup *Lookup
+	if link.Lookup != nil {
+		if lookup, err = resolveLookupVariables(link.Lookup, conf); err != nil {
+			return nil, err
+		}
+		if err := checkLookup(lookup); err != nil {
+			return nil, err
+		}
+	}
+
+	matcher, err := newMatcher(link, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	al := &AutoLinker{
+		link:     link,
+		matcher:  matcher,
+		template: template,
+		lookup:   lookup,
+	}
+
+	if len(link.Templates) > 0 {
+		if al.groupTemplates, err = resolveGroupTemplates(link, matcher, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	al.templateParts = map[string][]templatePart{template: splitTemplate(template)}
+	for _, t := range al.groupTemplates {
+		al.templateParts[t] = splitTemplate(t)
+	}
+
+	if len(link.Validators) > 0 {
+		if al.validators, err = compileValidators(link, matcher); err != nil {
+			return nil, err
+		}
+	}
+
+	if link.Conditions != nil {
+		if al.conditions, err = compileConditions(link.Conditions, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	return al, nil
+}
+
+// Replace will subsitute the regex's with the supplied links
+func (l *AutoLinker) Replace(message string) string {
+	return applyMatches(message, l.Matches(message))
+}
+
+// Matches returns the replacements the link makes in the message, in order.
+func (l *AutoLinker) Matches(message string) []*Match {
+	var matches []*Match
+	for _, m := range l.Explain(message) {
+		if m.Rejected == "" {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// Explain returns every match of the link in the message, in order, including the ones
+// that are not linked.
+func (l *AutoLinker) Explain(message string) []*Match {
+	if l.matcher == nil {
+		return nil
+	}
+
+	var matches []*Match
+	for _, sm := range l.matcher.FindAll(message) {
+		matches = append(matches, l.newMatch(message, sm))
+	}
+	return matches
+}
+
+// newMatch builds the replacement of a match found in the message.
+func (l *AutoLinker) newMatch(message string, sm *Submatch) *Match {
+	template, groupTemplate := l.template, false
+	var rejection error
+	captures := make(map[string]string, len(sm.Groups))
+	for _, g := range sm.Groups {
+		captures[g.Name] = g.Value
+
+		if t, ok := l.groupTemplates[g.Name]; ok && !groupTemplate {
+			template, groupTemplate = t, true
+		}
+
+		if v := l.validators[g.Name]; v != nil && rejection == nil {
+			if err := v.validate(g.Value); err != nil {
+				rejection = newLocalizedError("autolink.match.rejected", map[string]interface{}{"Group": g.Name, "Reason": err})
+			}
+		}
+	}
+
+	if template == "" && rejection == nil {
+		rejection = newLocalizedError("autolink.match.no_template", nil)
+	}
+
+	expand := sm.Expand
+	if expand == nil {
+		groups := sm.Groups
+		expand = func(template string, escape func(string) string) string {
+			return expandGroups(template, groups, escape)
+		}
+	}
+
+	m := &Match{
+		Start:       sm.Start,
+		End:         sm.End,
+		Text:        message[sm.Start:sm.End],
+		Replacement: expandTemplate(l.templateParts[template], expand),
+		Captures:    captures,
+		rejection:   rejection,
+		expand:      expand,
+	}
+	if rejection != nil {
+		m.Rejected = rejection.Error()
+	}
+	return m
+}
+
+// resolveGroupTemplates resolves the Templates of the link. Every template must name a group
+// of the matcher.
+func resolveGroupTemplates(link *Link, matcher Matcher, conf *Configuration) (map[string]string, error) {
+	templates := make(map[string]string, len(link.Templates))
+	for name, template := range link.Templates {
+		if !hasGroup(matcher, name) {
+			return nil, newLocalizedError("autolink.link.templates_unknown_group", map[string]interface{}{"Group": name})
+		}
+		if template == "" {
+			return nil, newLocalizedError("autolink.link.group_template_empty", map[string]interface{}{"Group": name})
+		}
+		resolved, err := resolveVariables(template, conf)
+		if err != nil {
+			return nil, err
+		}
+		templates[name] = resolved
+	}
+	return templates, nil
+}
+
+func hasGroup(matcher Matcher, name string) bool {
+	for _, group := range matcher.Groups() {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the destination of the first link in the replacement, if any.
+func (m *Match) URL() string {
+	destination := ""
+	markdown.Inspect(m.Replacement, func(node interface{}) bool {
+		if destination != "" {
+			return false
+		}
+		switch v := node.(type) {
+		case *markdown.InlineLink:
+			destination = v.Destination()
+		case *markdown.Autolink:
+			destination = v.Destination()
+		}
+		return true
+	})
+	return destination
+}
+
+// applyMatches replaces the text of every match in the message.
+func applyMatches(message string, matches []*Match) string {
+	if len(matches) == 0 {
+		return message
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, m := range matches {
+		b.WriteString(message[pos:m.Start])
+		b.WriteString(m.Replacement)
+		pos = m.End
+	}
+	b.WriteString(message[pos:])
+	return b.String()
+}
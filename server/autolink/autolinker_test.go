@@ -1,9 +1,10 @@
-package main
+package autolink
 
 import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAutolink(t *testing.T) {
@@ -155,7 +156,7 @@ func TestAutolink(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		al, _ := NewAutoLinker(tt.Link)
+		al, _ := NewAutoLinker(tt.Link, nil)
 		actual := al.Replace(tt.inputMessage)
 
 		assert.Equal(t, tt.expectedMessage, actual)
@@ -182,7 +183,74 @@ func TestAutolinkErrors(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		_, err := NewAutoLinker(tt.Link)
+		_, err := NewAutoLinker(tt.Link, nil)
 		assert.NotNil(t, err)
 	}
 }
+
+func TestAutolinkMatches(t *testing.T) {
+	al, err := NewAutoLinker(&Link{
+		Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+		Template: "[MM-$jira_id](https://mattermost.atlassian.net/browse/MM-$jira_id)",
+	}, nil)
+	require.Nil(t, err)
+
+	message := "MM-1 MM-22, and (MM-333)"
+	matches := al.Matches(message)
+	require.Len(t, matches, 2)
+
+	assert.Equal(t, 0, matches[0].Start)
+	assert.Equal(t, 4, matches[0].End)
+	assert.Equal(t, "MM-1", matches[0].Text)
+	assert.Equal(t, map[string]string{"jira_id": "1"}, matches[0].Captures)
+	assert.Equal(t, "https://mattermost.atlassian.net/browse/MM-1", matches[0].URL())
+
+	assert.Equal(t, "MM-22", message[matches[1].Start:matches[1].End])
+	assert.Equal(t, "[MM-22](https://mattermost.atlassian.net/browse/MM-22)", matches[1].Replacement)
+}
+
+func TestAutolinkNumberedGroups(t *testing.T) {
+	// Numbered groups count the groups of the pattern only, and $0 leaves out the prefix
+	// and suffix.
+	link := &Link{Pattern: "(MM)-(\\d+)", Template: "[$0]($1/$2)"}
+	al, err := NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "See [MM-12](MM/12).", al.Replace("See MM-12."))
+
+	link.DisableNonWordPrefix = true
+	link.DisableNonWordSuffix = true
+	al, err = NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "See [MM-12](MM/12).", al.Replace("See MM-12."))
+}
+
+func TestAutolinkGroupTemplates(t *testing.T) {
+	link := &Link{
+		Pattern: "(?P<jira>[A-Z]+-\\d+)|#(?P<gh>\\d+)",
+		Templates: map[string]string{
+			"jira": "[${jira}](https://mattermost.atlassian.net/browse/${jira})",
+			"gh":   "[#${gh}](https://github.com/mattermost/mattermost-server/issues/${gh})",
+		},
+	}
+	al, err := NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t,
+		"Fixed [MM-123](https://mattermost.atlassian.net/browse/MM-123) in [#456](https://github.com/mattermost/mattermost-server/issues/456)",
+		al.Replace("Fixed MM-123 in #456"))
+
+	// Without a template for the alternative that matched, the match is left alone.
+	link.Pattern = "(?P<jira>[A-Z]+-\\d+)|#(?P<gh>\\d+)|@(?P<user>\\w+)"
+	al, err = NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "@alice [#1](https://github.com/mattermost/mattermost-server/issues/1)", al.Replace("@alice #1"))
+	assert.Len(t, al.Matches("@alice #1"), 1)
+
+	link.Template = "[@${user}](https://example.com/${user})"
+	al, err = NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "[@alice](https://example.com/alice)", al.Replace("@alice"))
+
+	link.Templates["other"] = "x"
+	_, err = NewAutoLinker(link, nil)
+	assert.EqualError(t, err, `Templates refers to "other", which is not a named group of the pattern`)
+}
@@ -0,0 +1,101 @@
+package autolink
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Boundary modes, deciding where a match may start and end.
+const (
+	// boundariesWhitespace requires whitespace or the start of the message before a match,
+	// and whitespace, common punctuation or the end of the message after it.
+	boundariesWhitespace = "whitespace"
+
+	// boundariesUnicode also accepts Unicode punctuation and symbols and changes of script,
+	// so that terms embedded in Chinese or Japanese sentences, which have no spaces, match.
+	boundariesUnicode = "unicode"
+)
+
+func checkBoundaries(link *Link) error {
+	switch link.Boundaries {
+	case "", boundariesWhitespace, boundariesUnicode:
+		return nil
+	}
+	return newLocalizedError("autolink.link.unknown_boundaries", map[string]interface{}{"Boundaries": link.Boundaries})
+}
+
+// knownScripts lists the scripts checked first when looking up the script of a rune.
+var knownScripts = []*unicode.RangeTable{
+	unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+	unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew, unicode.Thai,
+	unicode.Devanagari,
+}
+
+// unsegmentedScripts are written without spaces between words, or, like Hangul, with
+// particles attached to the words. Any change to or from them is a boundary.
+var unsegmentedScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+	unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+}
+
+// scriptOf returns the script of a letter, or nil for runes shared between scripts, such as
+// digits, which never make a change of script.
+func scriptOf(r rune) *unicode.RangeTable {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return nil
+	}
+	for _, script := range knownScripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+	for _, script := range unicode.Scripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+	return nil
+}
+
+// isUnicodeBoundary reports whether a match may start or end between the runes a and b,
+// either of which is utf8.RuneError at the start or end of the message.
+func isUnicodeBoundary(a, b rune) bool {
+	if a == utf8.RuneError || b == utf8.RuneError {
+		return true
+	}
+
+	for _, r := range []rune{a, b} {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return true
+		}
+		if unicode.In(r, unsegmentedScripts...) {
+			return true
+		}
+	}
+
+	scriptA, scriptB := scriptOf(a), scriptOf(b)
+	return scriptA != nil && scriptB != nil && scriptA != scriptB
+}
+
+// atUnicodeBoundaries reports whether message[start:end] starts and ends at boundaries. The
+// prefix or suffix is not checked when disabled.
+func atUnicodeBoundaries(message string, start, end int, checkPrefix, checkSuffix bool) bool {
+	if start == end {
+		return false
+	}
+	if checkPrefix {
+		before, _ := utf8.DecodeLastRuneInString(message[:start])
+		first, _ := utf8.DecodeRuneInString(message[start:end])
+		if !isUnicodeBoundary(before, first) {
+			return false
+		}
+	}
+	if checkSuffix {
+		last, _ := utf8.DecodeLastRuneInString(message[start:end])
+		after, _ := utf8.DecodeRuneInString(message[end:])
+		if !isUnicodeBoundary(last, after) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnicodeBoundaries(t *testing.T) {
+	jira, err := NewAutoLinker(&Link{
+		Pattern:    "(MM)(-)(?P<jira_id>\\d+)",
+		Template:   "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+		Boundaries: boundariesUnicode,
+	}, nil)
+	require.Nil(t, err)
+
+	glossary, err := NewAutoLinker(&Link{
+		Name:       "glossary",
+		Type:       linkTypeDictionary,
+		Dictionary: map[string]string{"Mattermost": "mattermost", "東京": "tokyo", "Москва": "moscow"},
+		Template:   "[${term}](https://example.com/${value})",
+		Boundaries: boundariesUnicode,
+	}, nil)
+	require.Nil(t, err)
+
+	for _, tt := range []struct {
+		Language string
+		Message  string
+		Expected string
+	}{
+		{"English", "See MM-123.", "See [MM-123](https://mattermost.atlassian.net/browse/MM-123)."},
+		{"English inside a word", "XMM-123 and MM-123x", "XMM-123 and MM-123x"},
+		{"Chinese", "请查看MM-123的问题", "请查看[MM-123](https://mattermost.atlassian.net/browse/MM-123)的问题"},
+		{"Chinese punctuation", "问题（MM-123）已修复。", "问题（[MM-123](https://mattermost.atlassian.net/browse/MM-123)）已修复。"},
+		{"Japanese", "MM-123を確認してください", "[MM-123](https://mattermost.atlassian.net/browse/MM-123)を確認してください"},
+		{"Japanese katakana", "チケットMM-123です", "チケット[MM-123](https://mattermost.atlassian.net/browse/MM-123)です"},
+		{"Korean", "MM-123을 확인하세요", "[MM-123](https://mattermost.atlassian.net/browse/MM-123)을 확인하세요"},
+		{"Thai", "ดูMM-123ครับ", "ดู[MM-123](https://mattermost.atlassian.net/browse/MM-123)ครับ"},
+		{"Russian", "Задача MM-123, готово", "Задача [MM-123](https://mattermost.atlassian.net/browse/MM-123), готово"},
+		{"Russian without space", "ЗадачаMM-123", "Задача[MM-123](https://mattermost.atlassian.net/browse/MM-123)"},
+		{"Arabic", "راجع MM-123 من فضلك", "راجع [MM-123](https://mattermost.atlassian.net/browse/MM-123) من فضلك"},
+		{"German quotes", "„MM-123“ ist erledigt", "„[MM-123](https://mattermost.atlassian.net/browse/MM-123)“ ist erledigt"},
+		{"Spanish", "¿MM-123?", "¿[MM-123](https://mattermost.atlassian.net/browse/MM-123)?"},
+		{"digits are not a script", "MM-1234", "[MM-1234](https://mattermost.atlassian.net/browse/MM-1234)"},
+	} {
+		t.Run(tt.Language, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, jira.Replace(tt.Message))
+		})
+	}
+
+	for _, tt := range []struct {
+		Language string
+		Message  string
+		Expected string
+	}{
+		{"Chinese", "我在用Mattermost工作", "我在用[Mattermost](https://example.com/mattermost)工作"},
+		{"Japanese", "東京でMattermostを使う", "[東京](https://example.com/tokyo)で[Mattermost](https://example.com/mattermost)を使う"},
+		{"Russian", "Москва и Mattermost", "[Москва](https://example.com/moscow) и [Mattermost](https://example.com/mattermost)"},
+		{"Russian inside a word", "Москвабад", "Москвабад"},
+		{"English inside a word", "Mattermosts", "Mattermosts"},
+	} {
+		t.Run("dictionary "+tt.Language, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, glossary.Replace(tt.Message))
+		})
+	}
+
+	_, err = NewAutoLinker(&Link{Pattern: "MM", Template: "MM", Boundaries: "words"}, nil)
+	assert.EqualError(t, err, `unknown boundaries "words", expected whitespace or unicode`)
+}
+
+func TestUnicodeBoundariesLongestMatch(t *testing.T) {
+	tag, err := NewAutoLinker(&Link{
+		Pattern:    "(?P<tag>foo[-a-z]*)",
+		Template:   "[${tag}](https://example.com/${tag})",
+		Boundaries: boundariesUnicode,
+	}, nil)
+	require.Nil(t, err)
+
+	assert.Equal(t, "[foo-bar](https://example.com/foo-bar) 1", tag.Replace("foo-bar 1"))
+
+	// The match foo-bar is followed by a digit, which is not a boundary. The shorter match
+	// foo, followed by a hyphen, is not tried, and a later match must start after f.
+	assert.Equal(t, "foo-bar1", tag.Replace("foo-bar1"))
+	assert.Equal(t, "ffoo-bar1 [foo](https://example.com/foo)", tag.Replace("ffoo-bar1 foo"))
+}
+
+func TestWhitespaceBoundariesWithCJK(t *testing.T) {
+	// The default boundaries need whitespace, so terms embedded in CJK text do not match.
+	jira, err := NewAutoLinker(&Link{
+		Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+		Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+	}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "请查看MM-123的问题", jira.Replace("请查看MM-123的问题"))
+}
@@ -0,0 +1,301 @@
+package autolink
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+const (
+	// linkSyncInterval is how often every node checks the KV store for a newer revision of
+	// the links, with the kv storage.
+	linkSyncInterval = 5 * time.Second
+
+	// nodeReportInterval is how often a node reports the revision it runs when it does not
+	// change. Nodes that did not report for a few intervals are shown as not seen recently,
+	// and forgotten after nodeExpiry.
+	nodeReportInterval = time.Minute
+	nodeExpiry         = 24 * time.Hour
+
+	nodeKeyPrefix = "node_"
+	nodesKey      = "nodes"
+)
+
+// linkSyncer periodically loads the latest revision of the links on this node, so that
+// changes made on other nodes of a cluster take effect everywhere.
+type linkSyncer struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (p *Plugin) startLinkSync(interval time.Duration) *linkSyncer {
+	s := &linkSyncer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.syncLinks(); err != nil {
+					mlog.Warn("Failed to check for a newer revision of the links: " + err.Error())
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// close stops the checks and waits for a running one to finish.
+func (s *linkSyncer) close() {
+	close(s.stop)
+	<-s.done
+}
+
+// syncLinks makes the latest revision of the links in the KV store active on this node if
+// it runs another one. It does nothing with the config storage, where the server keeps the
+// configuration of every node in sync.
+func (p *Plugin) syncLinks() error {
+	p.syncLock.Lock()
+	defer p.syncLock.Unlock()
+
+	conf := p.getConfiguration()
+	if conf.Storage != storageKV {
+		return nil
+	}
+
+	head, err := p.getLinkHead()
+	if err != nil {
+		return err
+	}
+	if head.Revision == 0 || (head.Revision == conf.revision && head.Stamp == conf.stamp) {
+		p.getNodeReporter().report(conf.revision, false)
+		return nil
+	}
+
+	rev, err := p.getLinkRevision(head.Revision)
+	if err != nil {
+		return err
+	}
+	c := *conf
+	c.Links = rev.Links
+	c.revision, c.stamp = rev.Revision, rev.Stamp
+	p.applyConfiguration(&c)
+
+	mlog.Info("Loaded a newer revision of the links", mlog.Int("from_revision", conf.revision), mlog.Int("revision", rev.Revision))
+	return nil
+}
+
+// nodeStatus is what a node of the cluster reports about the links it runs.
+type nodeStatus struct {
+	NodeId   string
+	Revision int
+
+	// AppliedAt is when the node made the revision active, and CheckedAt when it last
+	// reported.
+	AppliedAt int64
+	CheckedAt int64
+}
+
+// nodeReporter records the revision of the links this node runs in the KV store, for the
+// status command.
+type nodeReporter struct {
+	api plugin.API
+	id  string
+
+	lock       sync.Mutex
+	status     nodeStatus
+	lastReport time.Time
+}
+
+func newNodeReporter(api plugin.API, id string) *nodeReporter {
+	return &nodeReporter{
+		api:    api,
+		id:     id,
+		status: nodeStatus{NodeId: id},
+	}
+}
+
+// getNodeReporter returns the reporter of this node, which is identified by its host name.
+func (p *Plugin) getNodeReporter() *nodeReporter {
+	p.nodeOnce.Do(func() {
+		id, err := os.Hostname()
+		if err != nil || id == "" {
+			id = model.NewId()
+		}
+		p.node = newNodeReporter(p.API, id)
+	})
+	return p.node
+}
+
+// report records the revision this node runs. Unless the revision was just applied, reports
+// of an unchanged revision are limited to one per nodeReportInterval.
+func (r *nodeReporter) report(revision int, applied bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	if !applied && revision == r.status.Revision && now.Sub(r.lastReport) < nodeReportInterval {
+		return
+	}
+
+	millis := now.UnixNano() / int64(time.Millisecond)
+	if applied || revision != r.status.Revision {
+		r.status.AppliedAt = millis
+	}
+	r.status.Revision = revision
+	r.status.CheckedAt = millis
+
+	data, err := json.Marshal(r.status)
+	if err != nil {
+		mlog.Error("Failed to encode the node status: " + err.Error())
+		return
+	}
+	if appErr := r.api.KVSet(nodeKeyPrefix+r.id, data); appErr != nil {
+		mlog.Warn("Failed to save the node status: " + appErr.Error())
+		return
+	}
+	r.lastReport = now
+
+	// Without atomic updates, two nodes registering at once may drop one of them from the
+	// list. The node adds itself back with its next report.
+	nodes, err := getNodeIDs(r.api)
+	if err != nil {
+		mlog.Warn("Failed to load the list of nodes: " + err.Error())
+		return
+	}
+	for _, id := range nodes {
+		if id == r.id {
+			return
+		}
+	}
+	if err := setNodeIDs(r.api, append(nodes, r.id)); err != nil {
+		mlog.Warn("Failed to save the list of nodes: " + err.Error())
+	}
+}
+
+func getNodeIDs(api plugin.API) ([]string, error) {
+	data, appErr := api.KVGet(nodesKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+	var nodes []string
+	if data == nil {
+		return nodes, nil
+	}
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+func setNodeIDs(api plugin.API, nodes []string) error {
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	if appErr := api.KVSet(nodesKey, data); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// getNodeStatuses returns the status reported by every node, sorted by node. Nodes that did
+// not report for nodeExpiry are forgotten.
+func (p *Plugin) getNodeStatuses() ([]*nodeStatus, error) {
+	ids, err := getNodeIDs(p.API)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := time.Now().Add(-nodeExpiry).UnixNano() / int64(time.Millisecond)
+	var statuses []*nodeStatus
+	var live []string
+	for _, id := range ids {
+		data, appErr := p.API.KVGet(nodeKeyPrefix + id)
+		if appErr != nil {
+			return nil, appErr
+		}
+		var status nodeStatus
+		if data == nil || json.Unmarshal(data, &status) != nil || status.CheckedAt < expiry {
+			if appErr := p.API.KVDelete(nodeKeyPrefix + id); appErr != nil {
+				mlog.Warn("Failed to delete the status of a node: "+appErr.Error(), mlog.String("node_id", id))
+			}
+			continue
+		}
+		statuses = append(statuses, &status)
+		live = append(live, id)
+	}
+
+	if len(live) < len(ids) {
+		if err := setNodeIDs(p.API, live); err != nil {
+			mlog.Warn("Failed to save the list of nodes: " + err.Error())
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeId < statuses[j].NodeId
+	})
+	return statuses, nil
+}
+
+// executeStatusCommand handles
+//
+//	/autolink status
+//
+// It shows the revision of the links every node of the cluster runs.
+func (p *Plugin) executeStatusCommand(T i18n.TranslateFunc) string {
+	conf := p.getConfiguration()
+	if conf.Storage != storageKV {
+		return T("autolink.command.status.not_kv", map[string]interface{}{"Links": len(p.links.Load().([]*AutoLinker))})
+	}
+
+	latest, err := p.getLinkHead()
+	if err != nil {
+		return T("autolink.command.status.failed", map[string]interface{}{"Error": translateError(T, err)})
+	}
+	head := latest.Revision
+	statuses, err := p.getNodeStatuses()
+	if err != nil {
+		return T("autolink.command.status.failed", map[string]interface{}{"Error": translateError(T, err)})
+	}
+
+	thisNode := p.getNodeReporter().id
+	unseen := time.Now().Add(-3*nodeReportInterval).UnixNano() / int64(time.Millisecond)
+
+	text := T("autolink.command.status.header", map[string]interface{}{"Revision": head})
+	for _, status := range statuses {
+		current := ""
+		if status.NodeId == thisNode {
+			current = T("autolink.command.status.this_node")
+		}
+		state := ""
+		switch {
+		case status.CheckedAt < unseen:
+			state = T("autolink.command.status.unseen")
+		case status.Revision < head:
+			state = T("autolink.command.status.behind")
+		}
+		text += "\n" + T("autolink.command.status.node", map[string]interface{}{
+			"Node":     status.NodeId,
+			"Current":  current,
+			"Revision": status.Revision,
+			"State":    state,
+			"Applied":  formatMillis(status.AppliedAt),
+			"Checked":  formatMillis(status.CheckedAt),
+		})
+	}
+	return text
+}
@@ -0,0 +1,150 @@
+package autolink
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupClusterNode starts the plugin of a node of a cluster whose nodes share the KV store.
+func setupClusterNode(t *testing.T, store map[string][]byte, nodeID string) *Plugin {
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = Configuration{
+			Storage: storageKV,
+			Links: []*Link{{
+				Name:     "mattermost",
+				Pattern:  "(Mattermost)",
+				Template: "[Mattermost](https://mattermost.com)",
+			}},
+		}
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", "admin").Return(&model.User{Username: "admin", Locale: "en"}, nil)
+	mockSharedKVStore(api, store)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.nodeOnce.Do(func() {
+		p.node = newNodeReporter(api, nodeID)
+	})
+	require.Nil(t, p.OnConfigurationChange())
+	return p
+}
+
+func TestClusterSync(t *testing.T) {
+	store := make(map[string][]byte)
+	a := setupClusterNode(t, store, "node-a")
+	b := setupClusterNode(t, store, "node-b")
+
+	w := doAPIRequest(a, "admin", http.MethodPost, "/api/v1/links", `{"Name": "jira", "Pattern": "(MM)(-)(?P<jira_id>\\d+)", "Template": "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(a, "MM-1"))
+
+	// The other node keeps its links until it checks for a newer revision.
+	assert.Equal(t, "MM-1", linkedMessage(b, "MM-1"))
+	require.Nil(t, b.syncLinks())
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(b, "MM-1"))
+
+	// Changes start from the latest revision, even on a node that did not check yet.
+	w = doAPIRequest(a, "admin", http.MethodPost, "/api/v1/links", `{"Name": "github", "Pattern": "#(?P<issue>\\d+)", "Template": "[#${issue}](https://github.com/mattermost/mattermost-server/issues/${issue})"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doAPIRequest(b, "admin", http.MethodDelete, "/api/v1/links/mattermost", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "Mattermost [#1](https://github.com/mattermost/mattermost-server/issues/1)", linkedMessage(b, "Mattermost #1"))
+
+	resp, _ := a.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink status"})
+	assert.Regexp(t, "^The latest revision of the links is \\*\\*4\\*\\*. Nodes of the cluster:\n"+
+		`\* \*\*node-a\*\* \(this node\): revision 3, behind, applied .+ UTC, last checked .+ UTC\n`+
+		`\* \*\*node-b\*\*: revision 4, applied .+ UTC, last checked .+ UTC$`, resp.Text)
+
+	require.Nil(t, a.syncLinks())
+	assert.Equal(t, "Mattermost [#1](https://github.com/mattermost/mattermost-server/issues/1)", linkedMessage(a, "Mattermost #1"))
+	resp, _ = a.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink status"})
+	assert.Contains(t, resp.Text, "* **node-a** (this node): revision 4, applied")
+}
+
+func TestClusterSyncTimer(t *testing.T) {
+	store := make(map[string][]byte)
+	a := setupClusterNode(t, store, "node-a")
+	b := setupClusterNode(t, store, "node-b")
+
+	w := doAPIRequest(a, "admin", http.MethodDelete, "/api/v1/links/mattermost", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	s := b.startLinkSync(10 * time.Millisecond)
+	for i := 0; i < 100 && linkedMessage(b, "Mattermost") != "Mattermost"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.close()
+	assert.Equal(t, "Mattermost", linkedMessage(b, "Mattermost"))
+}
+
+func TestClusterConcurrentChanges(t *testing.T) {
+	store := make(map[string][]byte)
+	a := setupClusterNode(t, store, "node-a")
+	b := setupClusterNode(t, store, "node-b")
+
+	// Both nodes save revision 2. Node b saves its revision over the one of node a, and node
+	// a saves the head last.
+	links := []*Link{{Name: "jira", Pattern: "(MM)(-)(?P<jira_id>\\d+)", Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})"}}
+	require.Nil(t, b.storeLinkRevision(links, &linkRevision{Change: changeCreate, Link: "jira"}))
+	lost := &linkRevision{Revision: 2, Stamp: model.NewId()}
+	require.Nil(t, a.setLinkHead(&linkHead{Revision: 2, Stamp: lost.Stamp}))
+
+	// Node a finds out, and points the head back to the revision that was kept.
+	assert.Equal(t, errLinksChangedConcurrently, a.checkLinkRevisionKept(lost))
+	head, err := a.getLinkHead()
+	require.Nil(t, err)
+	rev, err := a.getLinkRevision(2)
+	require.Nil(t, err)
+	assert.Equal(t, 2, head.Revision)
+	assert.Equal(t, rev.Stamp, head.Stamp)
+
+	require.Nil(t, a.syncLinks())
+	require.Nil(t, b.syncLinks())
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(a, "MM-1"))
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(b, "MM-1"))
+
+	// A revision saved with the number a node runs is still loaded.
+	require.Nil(t, a.storeLinkRevision(nil, &linkRevision{Change: changeDelete, Link: "jira"}))
+	stamp := model.NewId()
+	data, err := json.Marshal(&linkRevision{Revision: 3, Stamp: stamp, Links: []*Link{{Name: "mattermost", Pattern: "(Mattermost)", Template: "[Mattermost](https://mattermost.com)"}}})
+	require.Nil(t, err)
+	store[linkRevisionKey(3)] = data
+	require.Nil(t, a.setLinkHead(&linkHead{Revision: 3, Stamp: stamp}))
+	require.Nil(t, a.syncLinks())
+	assert.Equal(t, "[Mattermost](https://mattermost.com)", linkedMessage(a, "Mattermost"))
+}
+
+func TestClusterStatusForgetsNodes(t *testing.T) {
+	store := make(map[string][]byte)
+	a := setupClusterNode(t, store, "node-a")
+
+	old := time.Now().Add(-2*nodeExpiry).UnixNano() / int64(time.Millisecond)
+	data, _ := json.Marshal(nodeStatus{NodeId: "node-old", Revision: 1, AppliedAt: old, CheckedAt: old})
+	store[nodeKeyPrefix+"node-old"] = data
+	store[nodesKey] = []byte(`["node-old", "node-a"]`)
+
+	resp, _ := a.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink status"})
+	assert.NotContains(t, resp.Text, "node-old")
+	assert.Contains(t, resp.Text, "* **node-a** (this node): revision 1, applied")
+	assert.Nil(t, store[nodeKeyPrefix+"node-old"])
+	assert.Equal(t, `["node-a"]`, string(store[nodesKey]))
+}
+
+func TestClusterStatusConfigStorage(t *testing.T) {
+	p, _ := setupAPITest(t)
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink status"})
+	assert.Equal(t, "The links are kept in the server configuration, which every node of the cluster loads. This node runs 1 links.", resp.Text)
+}
@@ -0,0 +1,90 @@
+package autolink
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+)
+
+const commandTrigger = "autolink"
+
+// OnActivate registers the /autolink command, and starts sending webhook events and
+// indexing rewritten posts.
+func (p *Plugin) OnActivate() error {
+	p.lookups = newLookupCache(p.API)
+	p.webhooks = newWebhookDispatcher(defaultWebhookQueueSize)
+	p.webhooks.start(defaultWebhookWorkers)
+	p.rewritten = p.startRewrittenIndexer(maxQueuedRewrittenPosts)
+	p.linkSync = p.startLinkSync(linkSyncInterval)
+
+	return p.API.RegisterCommand(&model.Command{
+		Trigger:          commandTrigger,
+		DisplayName:      "Autolink",
+		Description:      "Manage the autolink plugin.",
+		AutoComplete:     true,
+		AutoCompleteDesc: "Available commands: revert, test, history, rollback, status, perf",
+		AutoCompleteHint: "[command]",
+	})
+}
+
+// OnDeactivate stops sending webhook events, indexing rewritten posts and checking for newer
+// revisions of the links.
+func (p *Plugin) OnDeactivate() error {
+	if p.webhooks != nil {
+		p.webhooks.close()
+	}
+	if p.rewritten != nil {
+		p.rewritten.close()
+	}
+	if p.linkSync != nil {
+		p.linkSync.close()
+	}
+	return nil
+}
+
+// ExecuteCommand handles the /autolink command. It is restricted to system admins, and replies
+// in the locale of the user.
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	fields := strings.Fields(args.Command)
+	if len(fields) == 0 || fields[0] != "/"+commandTrigger {
+		return nil, nil
+	}
+
+	T := p.translationFunc(args.UserId)
+
+	if !p.API.HasPermissionTo(args.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return commandResponse(T("autolink.command.not_admin")), nil
+	}
+
+	if len(fields) < 2 {
+		return commandResponse(T("autolink.command.help")), nil
+	}
+
+	switch fields[1] {
+	case "revert":
+		return commandResponse(p.executeRevertCommand(T, fields[2:])), nil
+	case "history":
+		return commandResponse(p.executeHistoryCommand(T)), nil
+	case "rollback":
+		return commandResponse(p.executeRollbackCommand(T, args.UserId, fields[2:])), nil
+	case "status":
+		return commandResponse(p.executeStatusCommand(T)), nil
+	case "perf":
+		return commandResponse(p.executePerfCommand(T)), nil
+	case "test":
+		// Keep the message as written, including its line breaks.
+		message := strings.TrimSpace(strings.TrimPrefix(args.Command, fields[0]))
+		message = strings.TrimSpace(strings.TrimPrefix(message, fields[1]))
+		return commandResponse(p.executeTestCommand(T, args.ChannelId, message)), nil
+	default:
+		return commandResponse(T("autolink.command.help")), nil
+	}
+}
+
+func commandResponse(text string) *model.CommandResponse {
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
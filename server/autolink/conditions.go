@@ -0,0 +1,174 @@
+package autolink
+
+import (
+	"regexp"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+)
+
+// conditions are the compiled Conditions of a link.
+type conditions struct {
+	message      *regexp.Regexp
+	channelName  *regexp.Regexp
+	channelTypes map[string]bool
+	teamNames    map[string]bool
+	any          []*conditions
+}
+
+func compileConditions(c *Conditions, conf *Configuration) (*conditions, error) {
+	compiled := &conditions{}
+
+	var err error
+	if compiled.message, err = compileConditionPattern("MessagePattern", c.MessagePattern, conf); err != nil {
+		return nil, err
+	}
+	if compiled.channelName, err = compileConditionPattern("ChannelNamePattern", c.ChannelNamePattern, conf); err != nil {
+		return nil, err
+	}
+
+	if len(c.ChannelTypes) > 0 {
+		compiled.channelTypes = make(map[string]bool)
+		for _, channelType := range c.ChannelTypes {
+			switch channelType {
+			case model.CHANNEL_OPEN, model.CHANNEL_PRIVATE, model.CHANNEL_DIRECT, model.CHANNEL_GROUP:
+				compiled.channelTypes[channelType] = true
+			default:
+				return nil, newLocalizedError("autolink.link.channel_type", map[string]interface{}{"Type": channelType})
+			}
+		}
+	}
+
+	if len(c.TeamNames) > 0 {
+		compiled.teamNames = make(map[string]bool)
+		for _, name := range c.TeamNames {
+			compiled.teamNames[name] = true
+		}
+	}
+
+	for _, alt := range c.Any {
+		if alt == nil {
+			continue
+		}
+		sub, err := compileConditions(alt, conf)
+		if err != nil {
+			return nil, err
+		}
+		compiled.any = append(compiled.any, sub)
+	}
+
+	return compiled, nil
+}
+
+func compileConditionPattern(field, pattern string, conf *Configuration) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if max := conf.maxPatternLength(); len(pattern) > max {
+		return nil, newLocalizedError("autolink.link.condition_pattern_length", map[string]interface{}{"Field": field, "Length": len(pattern), "Limit": max})
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newLocalizedError("autolink.link.condition_pattern", map[string]interface{}{"Field": field, "Error": err})
+	}
+	return re, nil
+}
+
+// postContext is the context conditions are evaluated against. The channel and team are
+// only loaded when a condition needs them, and at most once per post.
+type postContext struct {
+	api  plugin.API
+	post *model.Post
+
+	channel       *model.Channel
+	channelLoaded bool
+	team          *model.Team
+	teamLoaded    bool
+}
+
+func (pc *postContext) getChannel() *model.Channel {
+	if !pc.channelLoaded {
+		pc.channelLoaded = true
+		channel, appErr := pc.api.GetChannel(pc.post.ChannelId)
+		if appErr != nil {
+			mlog.Warn("Failed to load the channel to evaluate link conditions: "+appErr.Error(),
+				mlog.String("channel_id", pc.post.ChannelId))
+			return nil
+		}
+		pc.channel = channel
+	}
+	return pc.channel
+}
+
+func (pc *postContext) getTeam() *model.Team {
+	if !pc.teamLoaded {
+		pc.teamLoaded = true
+		channel := pc.getChannel()
+		if channel == nil || channel.TeamId == "" {
+			return nil
+		}
+		team, appErr := pc.api.GetTeam(channel.TeamId)
+		if appErr != nil {
+			mlog.Warn("Failed to load the team to evaluate link conditions: "+appErr.Error(),
+				mlog.String("team_id", channel.TeamId))
+			return nil
+		}
+		pc.team = team
+	}
+	return pc.team
+}
+
+// holds reports whether the post satisfies the conditions. Conditions that cannot be
+// evaluated, because the channel or team could not be loaded, do not hold.
+func (c *conditions) holds(pc *postContext) bool {
+	if c.message != nil && !c.message.MatchString(pc.post.Message) {
+		return false
+	}
+
+	if c.channelName != nil || c.channelTypes != nil {
+		channel := pc.getChannel()
+		if channel == nil {
+			return false
+		}
+		if c.channelName != nil && !c.channelName.MatchString(channel.Name) {
+			return false
+		}
+		if c.channelTypes != nil && !c.channelTypes[channel.Type] {
+			return false
+		}
+	}
+
+	if c.teamNames != nil {
+		team := pc.getTeam()
+		if team == nil || !c.teamNames[team.Name] {
+			return false
+		}
+	}
+
+	if len(c.any) == 0 {
+		return true
+	}
+	for _, alt := range c.any {
+		if alt.holds(pc) {
+			return true
+		}
+	}
+	return false
+}
+
+// applicableLinks returns the links whose conditions hold for the post.
+func (p *Plugin) applicableLinks(post *model.Post, links []*AutoLinker) []*AutoLinker {
+	return linksHolding(&postContext{api: p.API, post: post}, links)
+}
+
+// linksHolding returns the links whose conditions hold in the context.
+func linksHolding(pc *postContext, links []*AutoLinker) []*AutoLinker {
+	var applicable []*AutoLinker
+	for _, l := range links {
+		if l.conditions == nil || l.conditions.holds(pc) {
+			applicable = append(applicable, l)
+		}
+	}
+	return applicable
+}
@@ -0,0 +1,114 @@
+package autolink
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditions(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "github",
+			Pattern:  "#(?P<issue>\\d+)",
+			Template: "[#${issue}](https://github.com/mattermost/mattermost-server/issues/${issue})",
+			Conditions: &Conditions{
+				Any: []*Conditions{
+					{MessagePattern: "\\bPR\\b"},
+					{ChannelNamePattern: "^dev-"},
+				},
+			},
+		}, {
+			Name:     "jira",
+			Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+			Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+			Conditions: &Conditions{
+				ChannelTypes: []string{model.CHANNEL_OPEN, model.CHANNEL_PRIVATE},
+				TeamNames:    []string{"core"},
+			},
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("GetChannel", "town-square").Return(&model.Channel{Id: "town-square", Name: "town-square", Type: model.CHANNEL_OPEN, TeamId: "core"}, nil)
+	api.On("GetChannel", "dev-server").Return(&model.Channel{Id: "dev-server", Name: "dev-server", Type: model.CHANNEL_PRIVATE, TeamId: "other"}, nil)
+	api.On("GetChannel", "dm").Return(&model.Channel{Id: "dm", Name: "a__b", Type: model.CHANNEL_DIRECT}, nil)
+	api.On("GetChannel", "missing").Return(nil, model.NewAppError("GetChannel", "not_found", nil, "", http.StatusNotFound))
+	api.On("GetTeam", "core").Return(&model.Team{Id: "core", Name: "core"}, nil)
+	api.On("GetTeam", "other").Return(&model.Team{Id: "other", Name: "other"}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	for _, tt := range []struct {
+		Name      string
+		ChannelId string
+		Message   string
+		Expected  string
+	}{
+		{
+			"message condition",
+			"town-square",
+			"PR for #123",
+			"PR for [#123](https://github.com/mattermost/mattermost-server/issues/123)",
+		}, {
+			"channel name condition",
+			"dev-server",
+			"fixes #123",
+			"fixes [#123](https://github.com/mattermost/mattermost-server/issues/123)",
+		}, {
+			"no condition holds",
+			"town-square",
+			"fixes #123",
+			"fixes #123",
+		}, {
+			"channel type and team",
+			"town-square",
+			"MM-123",
+			"[MM-123](https://mattermost.atlassian.net/browse/MM-123)",
+		}, {
+			"wrong team",
+			"dev-server",
+			"MM-123",
+			"MM-123",
+		}, {
+			"wrong channel type",
+			"dm",
+			"MM-123 in a PR #1",
+			"MM-123 in a PR [#1](https://github.com/mattermost/mattermost-server/issues/1)",
+		}, {
+			"channel not found",
+			"missing",
+			"MM-123 #1",
+			"MM-123 #1",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{ChannelId: tt.ChannelId, Message: tt.Message})
+			assert.Equal(t, tt.Expected, post.Message)
+		})
+	}
+
+	api.AssertNumberOfCalls(t, "GetChannel", 7)
+}
+
+func TestConditionsValidation(t *testing.T) {
+	link := &Link{Pattern: "(MM)", Template: "MM", Conditions: &Conditions{ChannelTypes: []string{"X"}}}
+	_, err := NewAutoLinker(link, nil)
+	assert.EqualError(t, err, `unknown channel type "X", expected O, P, D or G`)
+
+	link.Conditions = &Conditions{Any: []*Conditions{{MessagePattern: "("}}}
+	_, err = NewAutoLinker(link, nil)
+	assert.Contains(t, err.Error(), "MessagePattern: error parsing regexp")
+}
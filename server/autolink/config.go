@@ -0,0 +1,153 @@
+package autolink
+
+// Link represents a pattern to autolink
+type Link struct {
+	Name string
+
+	// Type selects how the link finds the text it applies to: regexp, the default, matches
+	// Pattern as a regular expression, simple matches Pattern in the simple pattern syntax
+	// and dictionary matches the terms of Dictionary.
+	Type string
+
+	Pattern              string
+	Template             string
+	DisableNonWordPrefix bool
+	DisableNonWordSuffix bool
+
+	// Boundaries selects where matches may start and end: whitespace, the default, or
+	// unicode, which also accepts Unicode punctuation and changes of script, for languages
+	// written without spaces. DisableNonWordPrefix and DisableNonWordSuffix turn the checks
+	// off in both modes.
+	Boundaries string
+
+	// Dictionary maps terms to values, for links of the dictionary type. Terms are matched
+	// as whole words, and templates refer to the matched term as ${term} and to its value
+	// as ${value}.
+	Dictionary map[string]string
+
+	// Templates maps named groups of the pattern to the template used when the group takes
+	// part in a match, so that each alternative of a pattern can have its own template.
+	// Template is used for matches where none of these groups took part.
+	Templates map[string]string
+
+	// Validators maps named groups of the pattern to checks their captures must pass for a
+	// match to be linked.
+	Validators map[string]*Validator
+
+	// WebhookURL, if set, receives an event for every match after a post is saved. Events are
+	// signed with WebhookSecret when it is set.
+	WebhookURL    string
+	WebhookSecret string
+
+	// Lookup, if set, fetches details about every match to build a richer replacement.
+	Lookup *Lookup
+
+	// Conditions, if set, restrict the posts the link is applied to.
+	Conditions *Conditions
+}
+
+// Conditions on the context of a post. A post must satisfy every condition that is set, and
+// at least one of Any if it is not empty.
+type Conditions struct {
+	// MessagePattern must match somewhere in the message.
+	MessagePattern string
+
+	// ChannelNamePattern must match the name of the channel.
+	ChannelNamePattern string
+
+	// ChannelTypes lists the types of channel allowed: O for public, P for private, D for
+	// direct and G for group message channels.
+	ChannelTypes []string
+
+	// TeamNames lists the names of the teams allowed. Direct and group message channels
+	// belong to no team and never match.
+	TeamNames []string
+
+	Any []*Conditions
+}
+
+// Validator checks the capture of a named group. Every check that is set must pass.
+type Validator struct {
+	// Min and Max bound the numeric value of the capture.
+	Min *int64
+	Max *int64
+
+	// MinLength and MaxLength bound the number of characters of the capture.
+	MinLength int
+	MaxLength int
+
+	// Values lists the allowed captures.
+	Values []string
+
+	// Checksum names a check digit algorithm the capture must pass: luhn or isbn.
+	Checksum string
+}
+
+// Lookup describes an HTTP service returning JSON details about a match, such as the title
+// and status of a ticket.
+type Lookup struct {
+	// URL is expanded with the captures of the match like a template.
+	URL     string
+	Headers map[string]string
+
+	// Template replaces the link's template when the lookup succeeds. Fields of the response
+	// are inserted with ${lookup:field}, using dots for nested fields.
+	Template string
+
+	// TimeoutMilliseconds bounds a single request, after which the link's template is used.
+	// CacheSeconds is how long responses are cached. Zero uses the default.
+	TimeoutMilliseconds int
+	CacheSeconds        int
+}
+
+// DisplayName identifies the link in logs, post props and commands. Unnamed links are
+// identified by their pattern.
+func (l *Link) DisplayName() string {
+	if l == nil {
+		return ""
+	}
+	if l.Name != "" {
+		return l.Name
+	}
+	return l.Pattern
+}
+
+// Configuration from config.json
+type Configuration struct {
+	// Version is the version of the configuration schema. Older configurations are migrated
+	// when they are loaded, and Migrations lists the names of the migrations that ran on
+	// them. Both are saved with the links when they are changed through the API.
+	Version    int
+	Migrations []string
+
+	// Storage selects where the links are kept: config, the default, keeps them in Links,
+	// and kv keeps them in the KV store as numbered revisions. Links are imported into the
+	// KV store when it holds none yet, and ignored afterwards.
+	Storage string
+
+	Links []*Link
+
+	// Variables can be referenced from the templates of the links as ${var:name}.
+	Variables map[string]string
+
+	// Limits on the patterns accepted when the configuration is loaded. Zero uses the default.
+	MaxPatternLength int
+	MaxProgramSize   int
+	MaxCaptureGroups int
+
+	// Budget for processing a single post. Posts exceeding it are left unchanged.
+	MaxPostProcessingMilliseconds int
+	MaxPostProcessingBytes        int
+
+	// SlowLinkMilliseconds is the time a link may take on a single post before it is logged
+	// as slow. Zero uses the default.
+	SlowLinkMilliseconds int
+
+	// Trace logs how posts are linked, to find out why a link does not apply.
+	Trace *TraceSettings
+
+	// revision and stamp identify the revision of the links loaded from the KV store, with
+	// the kv storage. They are not settings.
+	revision int
+	stamp    string
+}
@@ -0,0 +1,129 @@
+package autolink
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Groups of the matches of a dictionary link.
+const (
+	dictionaryTermGroup  = "term"
+	dictionaryValueGroup = "value"
+)
+
+// dictionaryMatcher matches the terms of a dictionary as whole words.
+type dictionaryMatcher struct {
+	values map[string]string
+
+	// terms lists the terms by their first rune, longest first so that the longest term
+	// at a position wins.
+	terms map[rune][]string
+
+	checkPrefix, checkSuffix bool
+	unicodeBoundaries        bool
+}
+
+func newDictionaryMatcher(link *Link, conf *Configuration) (Matcher, error) {
+	if link.Name == "" {
+		return nil, newLocalizedError("autolink.link.dictionary_name", nil)
+	}
+	if len(link.Dictionary) == 0 {
+		return nil, newLocalizedError("autolink.link.dictionary_empty", nil)
+	}
+	if err := checkBoundaries(link); err != nil {
+		return nil, err
+	}
+
+	m := &dictionaryMatcher{
+		values:      link.Dictionary,
+		terms:       make(map[rune][]string),
+		checkPrefix: !link.DisableNonWordPrefix,
+		checkSuffix: !link.DisableNonWordSuffix,
+
+		unicodeBoundaries: link.Boundaries == boundariesUnicode,
+	}
+	for term := range link.Dictionary {
+		if term == "" {
+			return nil, newLocalizedError("autolink.link.dictionary_empty_term", nil)
+		}
+		if max := conf.maxPatternLength(); len(term) > max {
+			return nil, newLocalizedError("autolink.link.dictionary_term_length", nil)
+		}
+		first, _ := utf8.DecodeRuneInString(term)
+		m.terms[first] = append(m.terms[first], term)
+	}
+	for _, terms := range m.terms {
+		sort.Slice(terms, func(i, j int) bool {
+			if len(terms[i]) != len(terms[j]) {
+				return len(terms[i]) > len(terms[j])
+			}
+			return terms[i] < terms[j]
+		})
+	}
+
+	return m, nil
+}
+
+func (m *dictionaryMatcher) Groups() []string {
+	return []string{dictionaryTermGroup, dictionaryValueGroup}
+}
+
+func (m *dictionaryMatcher) FindAll(message string) []*Submatch {
+	var matches []*Submatch
+	previous := utf8.RuneError
+	for pos := 0; pos < len(message); {
+		r, size := utf8.DecodeRuneInString(message[pos:])
+
+		if !m.checkPrefix || m.isBoundary(previous, r) {
+			if term := m.termAt(message, pos, r); term != "" {
+				matches = append(matches, &Submatch{
+					Start: pos,
+					End:   pos + len(term),
+					Groups: []Group{
+						{Name: dictionaryTermGroup, Value: term},
+						{Name: dictionaryValueGroup, Value: m.values[term]},
+					},
+				})
+				pos += len(term)
+				previous, _ = utf8.DecodeLastRuneInString(term)
+				continue
+			}
+		}
+
+		previous = r
+		pos += size
+	}
+	return matches
+}
+
+// termAt returns the longest term found at pos in the message, which starts with r.
+func (m *dictionaryMatcher) termAt(message string, pos int, r rune) string {
+	for _, term := range m.terms[r] {
+		end := pos + len(term)
+		if end > len(message) || message[pos:end] != term {
+			continue
+		}
+		if m.checkSuffix && end < len(message) {
+			last, _ := utf8.DecodeLastRuneInString(term)
+			if next, _ := utf8.DecodeRuneInString(message[end:]); !m.isBoundary(last, next) {
+				continue
+			}
+		}
+		return term
+	}
+	return ""
+}
+
+// isBoundary reports whether a term may start or end between the runes a and b. By default,
+// terms must not be preceded or followed by letters or digits.
+func (m *dictionaryMatcher) isBoundary(a, b rune) bool {
+	if m.unicodeBoundaries {
+		return isUnicodeBoundary(a, b)
+	}
+	return !isWordRune(a) || !isWordRune(b)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
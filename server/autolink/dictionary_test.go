@@ -0,0 +1,59 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictionary(t *testing.T) {
+	link := &Link{
+		Name: "glossary",
+		Type: linkTypeDictionary,
+		Dictionary: map[string]string{
+			"LHS":         "lhs",
+			"RHS":         "rhs",
+			"Mana":        "mana",
+			"Mana Points": "mana-points",
+			"C++":         "cpp",
+		},
+		Template: "[${term}](https://docs.mattermost.com/process/training.html#${value})",
+	}
+	al, err := NewAutoLinker(link, nil)
+	require.Nil(t, err)
+
+	for message, expected := range map[string]string{
+		"Check the LHS":         "Check the [LHS](https://docs.mattermost.com/process/training.html#lhs)",
+		"LHS, RHS.":             "[LHS](https://docs.mattermost.com/process/training.html#lhs), [RHS](https://docs.mattermost.com/process/training.html#rhs).",
+		"LHSX and XLHS":         "LHSX and XLHS",
+		"Mana Points and Mana":  "[Mana Points](https://docs.mattermost.com/process/training.html#mana-points) and [Mana](https://docs.mattermost.com/process/training.html#mana)",
+		"We use C++ here":       "We use [C++](https://docs.mattermost.com/process/training.html#cpp) here",
+		"Überlhs LHS":           "Überlhs [LHS](https://docs.mattermost.com/process/training.html#lhs)",
+		"ÄLHS is not a term":    "ÄLHS is not a term",
+		"(LHS)":                 "([LHS](https://docs.mattermost.com/process/training.html#lhs))",
+		"nothing to link here!": "nothing to link here!",
+	} {
+		assert.Equal(t, expected, al.Replace(message), message)
+	}
+
+	link.DisableNonWordPrefix = true
+	al, err = NewAutoLinker(link, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "X[LHS](https://docs.mattermost.com/process/training.html#lhs)", al.Replace("XLHS"))
+
+	_, err = NewAutoLinker(&Link{Type: linkTypeDictionary, Template: "x", Dictionary: map[string]string{"a": "b"}}, nil)
+	assert.EqualError(t, err, "dictionary links must have a name")
+
+	_, err = NewAutoLinker(&Link{Name: "empty", Type: linkTypeDictionary, Template: "x"}, nil)
+	assert.EqualError(t, err, "Dictionary was empty")
+
+	_, err = NewAutoLinker(&Link{
+		Name:       "glossary",
+		Type:       linkTypeDictionary,
+		Template:   "x",
+		Dictionary: map[string]string{"a": "b"},
+		Templates:  map[string]string{"id": "x"},
+	}, nil)
+	assert.EqualError(t, err, `Templates refers to "id", which is not a named group of the pattern`)
+}
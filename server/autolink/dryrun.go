@@ -0,0 +1,69 @@
+package autolink
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+// executeTestCommand handles
+//
+//	/autolink test <message>
+//
+// It shows how the message would be linked if it was posted in the channel, and why matches
+// were left alone, without posting anything.
+func (p *Plugin) executeTestCommand(T i18n.TranslateFunc, channelID, message string) string {
+	if message == "" {
+		return T("autolink.command.test.usage")
+	}
+
+	post := &model.Post{ChannelId: channelID, Message: message}
+	links := p.links.Load().([]*AutoLinker)
+	applicable := p.applicableLinks(post, links)
+	result := p.replaceLinks(message, applicable, newProcessingBudget(p.getConfiguration()))
+
+	var b strings.Builder
+	if result.exhaustedBy != nil {
+		b.WriteString(T("autolink.command.test.exhausted", map[string]interface{}{"Link": result.exhaustedBy.link.DisplayName()}) + "\n")
+	} else {
+		b.WriteString(T("autolink.command.test.posted_as", map[string]interface{}{"Message": result.message}) + "\n")
+	}
+
+	isDiscarded := make(map[*AutoLinker]bool, len(result.discarded))
+	for _, l := range result.discarded {
+		isDiscarded[l] = true
+	}
+
+	isApplicable := make(map[*AutoLinker]bool, len(applicable))
+	for _, l := range applicable {
+		isApplicable[l] = true
+	}
+
+	for _, l := range links {
+		name := l.link.DisplayName()
+		if m, ok := l.matcher.(*regexpMatcher); ok && l.link.Type == linkTypeSimple {
+			b.WriteString(T("autolink.command.test.compiled", map[string]interface{}{"Link": name, "Pattern": l.link.Pattern, "Expression": m.expression}) + "\n")
+		}
+		if !isApplicable[l] {
+			b.WriteString(T("autolink.command.test.skipped", map[string]interface{}{"Link": name}) + "\n")
+			continue
+		}
+
+		for _, e := range result.entities {
+			if e.Link == name {
+				b.WriteString(T("autolink.command.test.linked", map[string]interface{}{"Link": name, "Text": e.Text, "URL": e.URL}) + "\n")
+			}
+		}
+		if isDiscarded[l] {
+			b.WriteString(T("autolink.command.test.unsafe", map[string]interface{}{"Link": name}) + "\n")
+		}
+		for _, m := range l.Explain(message) {
+			if m.rejection != nil {
+				b.WriteString(T("autolink.command.test.rejected", map[string]interface{}{"Link": name, "Text": m.Text, "Reason": translateError(T, m.rejection)}) + "\n")
+			}
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
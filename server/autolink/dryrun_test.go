@@ -0,0 +1,79 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestCommand(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "cve",
+			Pattern:  "CVE-(?P<year>\\d{4})-(?P<id>\\d+)",
+			Template: "[CVE-${year}-${id}](https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-${year}-${id})",
+			Validators: map[string]*Validator{
+				"year": {Min: int64Ptr(1999)},
+			},
+		}, {
+			Name:       "github",
+			Pattern:    "#(?P<issue>\\d+)",
+			Template:   "[#${issue}](https://github.com/mattermost/mattermost-server/issues/${issue})",
+			Conditions: &Conditions{MessagePattern: "\\bPR\\b"},
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Locale: "en"}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink test CVE-0000-1 and CVE-2018-1000001 in #12"})
+	assert.Equal(t, "The message would be posted as:\n"+
+		"```\nCVE-0000-1 and [CVE-2018-1000001](https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2018-1000001) in #12\n```\n"+
+		"* **cve**: linked `CVE-2018-1000001` to https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2018-1000001\n"+
+		"* **cve**: did not link `CVE-0000-1`, year: \"0000\" is less than 1999.\n"+
+		"* **github**: skipped, its conditions do not hold.", resp.Text)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink test"})
+	assert.Equal(t, "Usage: `/autolink test <message>`.", resp.Text)
+}
+
+func TestTestCommandSimplePattern(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "jira",
+			Type:     linkTypeSimple,
+			Pattern:  "MM-{id:digits}",
+			Template: "[MM-${id}](https://mattermost.atlassian.net/browse/MM-${id})",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Locale: "en"}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink test MM-1"})
+	assert.Contains(t, resp.Text, "* **jira**: pattern `MM-{id:digits}` compiles to `MM-(?P<id>\\d+)`.\n")
+	assert.Contains(t, resp.Text, "* **jira**: linked `MM-1` to https://mattermost.atlassian.net/browse/MM-1")
+}
@@ -0,0 +1,95 @@
+package autolink
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const (
+	// propEntities lists the matches made in a post, so that integrations can find the
+	// references in a post without parsing its markdown.
+	propEntities = "autolink_entities"
+
+	// maxEntitiesPropRunes bounds the size of the entities prop, leaving room in the post
+	// props for the original message and other integrations.
+	maxEntitiesPropRunes = model.POST_PROPS_MAX_USER_RUNES / 2
+)
+
+// Entity describes a single match made in a post.
+type Entity struct {
+	Link     string
+	Text     string
+	Captures map[string]string `json:",omitempty"`
+	URL      string            `json:",omitempty"`
+}
+
+func newEntity(l *AutoLinker, m *Match) *Entity {
+	return &Entity{
+		Link:     l.link.DisplayName(),
+		Text:     m.Text,
+		Captures: m.Captures,
+		URL:      m.URL(),
+	}
+}
+
+// toProp converts the entity to the generic types post props can hold when passed between
+// the server and the plugin.
+func (e *Entity) toProp() map[string]interface{} {
+	prop := map[string]interface{}{
+		"Link": e.Link,
+		"Text": e.Text,
+	}
+	if len(e.Captures) > 0 {
+		captures := make(map[string]interface{}, len(e.Captures))
+		for name, value := range e.Captures {
+			captures[name] = value
+		}
+		prop["Captures"] = captures
+	}
+	if e.URL != "" {
+		prop["URL"] = e.URL
+	}
+	return prop
+}
+
+// addEntitiesProp lists the entities in the post props. Entities that do not fit within
+// maxEntitiesPropRunes are left out.
+func addEntitiesProp(post *model.Post, entities []*Entity) {
+	prop := make([]interface{}, 0, len(entities))
+	size := 2
+	for _, e := range entities {
+		p := e.toProp()
+		data, err := json.Marshal(p)
+		if err != nil {
+			continue
+		}
+		size += utf8.RuneCount(data) + 1
+		if size > maxEntitiesPropRunes {
+			break
+		}
+		prop = append(prop, p)
+	}
+	post.AddProp(propEntities, prop)
+}
+
+// postEntities returns the entities listed in the post props.
+func postEntities(post *model.Post) []*Entity {
+	prop, ok := post.Props[propEntities]
+	if !ok {
+		return nil
+	}
+
+	// Round trip through JSON to read the entities whether the props were built by the
+	// plugin or loaded from the database.
+	data, err := json.Marshal(prop)
+	if err != nil {
+		return nil
+	}
+	var entities []*Entity
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil
+	}
+	return entities
+}
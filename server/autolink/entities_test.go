@@ -0,0 +1,86 @@
+package autolink
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntities(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}, {
+			Name:     "jira",
+			Pattern:  "(?P<project>MM|PLT)(-)(?P<jira_id>\\d+)",
+			Template: "[${project}-${jira_id}](https://mattermost.atlassian.net/browse/${project}-${jira_id})",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.OnConfigurationChange()
+
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "Mattermost fixed MM-123 and PLT-4"})
+	expected := []*Entity{{
+		Link: "mattermost",
+		Text: "Mattermost",
+		URL:  "https://mattermost.com",
+	}, {
+		Link:     "jira",
+		Text:     "MM-123",
+		Captures: map[string]string{"project": "MM", "jira_id": "123"},
+		URL:      "https://mattermost.atlassian.net/browse/MM-123",
+	}, {
+		Link:     "jira",
+		Text:     "PLT-4",
+		Captures: map[string]string{"project": "PLT", "jira_id": "4"},
+		URL:      "https://mattermost.atlassian.net/browse/PLT-4",
+	}}
+	assert.Equal(t, expected, postEntities(post))
+
+	// Props loaded from the database hold generic JSON values.
+	var props model.StringInterface
+	require.Nil(t, json.Unmarshal([]byte(model.StringInterfaceToJson(post.Props)), &props))
+	assert.Equal(t, expected, postEntities(&model.Post{Props: props}))
+
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "Nothing to see here"})
+	assert.Nil(t, postEntities(post))
+}
+
+func TestEntitiesPropSize(t *testing.T) {
+	var entities []*Entity
+	for i := 0; i < 500; i++ {
+		entities = append(entities, &Entity{
+			Link: "jira",
+			Text: fmt.Sprintf("MM-%d", i),
+			URL:  fmt.Sprintf("https://mattermost.atlassian.net/browse/MM-%d", i),
+		})
+	}
+
+	post := &model.Post{}
+	addEntitiesProp(post, entities)
+
+	stored := postEntities(post)
+	assert.True(t, len(stored) > 0)
+	assert.True(t, len(stored) < len(entities))
+	assert.Equal(t, entities[:len(stored)], stored)
+	assert.True(t, len(model.StringInterfaceToJson(post.Props)) <= maxEntitiesPropRunes+len(propEntities)+10)
+	assert.False(t, strings.Contains(model.StringInterfaceToJson(post.Props), "MM-499"))
+}
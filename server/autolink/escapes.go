@@ -0,0 +1,238 @@
+package autolink
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/utils/markdown"
+)
+
+// escapedText is text as the links match it, along with the markdown source it was read from.
+// They differ where the source has backslash escapes or character references, such as \* or
+// &amp;, which the links match as the characters they stand for.
+type escapedText struct {
+	text, raw string
+
+	// offsets holds the position in raw of every byte of text, followed by len(raw). The
+	// bytes of an escape or a reference after the first have no position of their own and
+	// hold -1. It is nil if text and raw are the same.
+	offsets []int
+}
+
+// isEscapableByte reports whether a backslash before c escapes it, which is the case for
+// ASCII punctuation.
+func isEscapableByte(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// unescapeText reads text from the start of raw, resolving backslash escapes and character
+// references the way the markdown parser does. It returns false if raw does not start with
+// the source of text.
+func unescapeText(raw, text string) (*escapedText, bool) {
+	var b strings.Builder
+	var offsets []int
+	escaped := false
+	i := 0
+	for b.Len() < len(text) && i < len(raw) {
+		unit, size := raw[i:i+1], 1
+		switch raw[i] {
+		case '\\':
+			if i+1 < len(raw) && isEscapableByte(raw[i+1]) {
+				unit, size = raw[i+1:i+2], 2
+			}
+		case '&':
+			if semicolon := strings.IndexByte(raw[i+1:], ';'); semicolon >= 0 {
+				if s := markdown.CharacterReference(raw[i+1 : i+1+semicolon]); s != "" {
+					unit, size = s, semicolon+2
+				}
+			}
+		}
+		escaped = escaped || size > 1
+
+		b.WriteString(unit)
+		offsets = append(offsets, i)
+		for j := 1; j < len(unit); j++ {
+			offsets = append(offsets, -1)
+		}
+		i += size
+	}
+	if b.String() != text {
+		return nil, false
+	}
+
+	if !escaped {
+		return &escapedText{text: text, raw: text}, true
+	}
+	return &escapedText{text: text, raw: raw[:i], offsets: append(offsets, i)}, true
+}
+
+// aligned returns the matches of text that start and end at the boundaries of escapes and
+// references, and can be replaced in raw.
+func (t *escapedText) aligned(matches []*Match) []*Match {
+	if t.offsets == nil {
+		return matches
+	}
+
+	var kept []*Match
+	for _, m := range matches {
+		if t.offsets[m.Start] >= 0 && t.offsets[m.End] >= 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// replace returns the text with the aligned matches replaced, in both text and raw. The
+// replacements are markdown, so they are the same in both.
+func (t *escapedText) replace(matches []*Match) *escapedText {
+	if t.offsets == nil {
+		text := applyMatches(t.text, matches)
+		return &escapedText{text: text, raw: text}
+	}
+
+	var text, raw strings.Builder
+	var offsets []int
+	copyRange := func(start, end int) {
+		text.WriteString(t.text[start:end])
+		rawStart, rawEnd := t.offsets[start], t.offsets[end]
+		for i := start; i < end; i++ {
+			if t.offsets[i] < 0 {
+				offsets = append(offsets, -1)
+				continue
+			}
+			offsets = append(offsets, raw.Len()+t.offsets[i]-rawStart)
+		}
+		raw.WriteString(t.raw[rawStart:rawEnd])
+	}
+
+	pos := 0
+	for _, m := range matches {
+		copyRange(pos, m.Start)
+		text.WriteString(m.Replacement)
+		for i := 0; i < len(m.Replacement); i++ {
+			offsets = append(offsets, raw.Len()+i)
+		}
+		raw.WriteString(m.Replacement)
+		pos = m.End
+	}
+	copyRange(pos, len(t.text))
+
+	return &escapedText{text: text.String(), raw: raw.String(), offsets: append(offsets, raw.Len())}
+}
+
+// textRunSource returns the source of a run of adjacent text nodes, and its position in the
+// message. The parser makes a text node of every escape and reference, which gives the run
+// gaps: the range of an escaped character leaves out its backslash, and the range of a
+// reference has the length of the characters it stands for.
+func textRunSource(message string, run []*markdown.Text) (*escapedText, int, bool) {
+	var text strings.Builder
+	for _, t := range run {
+		text.WriteString(t.Text)
+	}
+
+	start := run[0].Range.Position
+	if start > 0 && start < len(message) && message[start-1] == '\\' && isEscapableByte(message[start]) {
+		if source, ok := unescapeText(message[start-1:], text.String()); ok {
+			return source, start - 1, true
+		}
+	}
+	source, ok := unescapeText(message[start:], text.String())
+	return source, start, ok
+}
+
+// Where the groups of a part of a template are inserted.
+const (
+	templateMarkup = iota
+	templateText
+	templateDestination
+)
+
+// templatePart is a part of a template whose groups are escaped the same way.
+type templatePart struct {
+	template string
+	escape   func(string) string
+}
+
+// splitTemplate splits a markdown template by where its groups are inserted, so that the
+// characters of a capture cannot change the markup around it: groups in the label of a link
+// or an image are escaped as markdown, and groups in its destination are percent-encoded.
+// Groups elsewhere, such as in plain text, bare URLs or code spans, are inserted as they are.
+func splitTemplate(template string) []templatePart {
+	kinds := make([]int, len(template))
+	mark := func(r markdown.Range, kind int) {
+		for i := r.Position; i < r.End && i < len(kinds); i++ {
+			kinds[i] = kind
+		}
+	}
+	markLabel := func(children []markdown.Inline) {
+		for _, child := range children {
+			markdown.InspectInline(child, func(inline markdown.Inline) bool {
+				if t, ok := inline.(*markdown.Text); ok {
+					mark(t.Range, templateText)
+				}
+				return true
+			})
+		}
+	}
+
+	markdown.Inspect(template, func(node interface{}) bool {
+		switch v := node.(type) {
+		case *markdown.InlineLink:
+			markLabel(v.Children)
+			mark(v.RawDestination, templateDestination)
+		case *markdown.InlineImage:
+			markLabel(v.Children)
+			mark(v.RawDestination, templateDestination)
+		case *markdown.ReferenceLink:
+			markLabel(v.Children)
+		case *markdown.ReferenceImage:
+			markLabel(v.Children)
+		}
+		return true
+	})
+
+	escapes := map[int]func(string) string{
+		templateText:        markdownEscaper.Replace,
+		templateDestination: escapeDestination,
+	}
+	var parts []templatePart
+	start := 0
+	for i := 1; i <= len(template); i++ {
+		if i == len(template) || kinds[i] != kinds[start] {
+			parts = append(parts, templatePart{template: template[start:i], escape: escapes[kinds[start]]})
+			start = i
+		}
+	}
+	return parts
+}
+
+// expandTemplate expands the parts of a template with expand, escaping their groups.
+func expandTemplate(parts []templatePart, expand func(template string, escape func(string) string) string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(expand(part.template, part.escape))
+	}
+	return b.String()
+}
+
+// escapeDestination percent-encodes the characters of a value that are not allowed in a
+// URL, or that could end the destination of a link or escape markdown in it. Characters
+// with a meaning in URLs, such as / and ?, are kept.
+func escapeDestination(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isDestinationByte(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// isDestinationByte reports whether c is kept as it is in the destination of a link.
+func isDestinationByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("-._~:/?#@!$&'+,;=%", c) >= 0
+}
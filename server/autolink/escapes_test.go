@@ -0,0 +1,114 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnescapeText(t *testing.T) {
+	for name, tc := range map[string]struct {
+		raw, text string
+		source    string
+		offsets   []int
+	}{
+		"plain":           {raw: "MM-12 and more", text: "MM-12", source: "MM-12"},
+		"escape":          {raw: "MM\\-12", text: "MM-12", source: "MM\\-12", offsets: []int{0, 1, 2, 4, 5, 6}},
+		"entity":          {raw: "AT&amp;T", text: "AT&T", source: "AT&amp;T", offsets: []int{0, 1, 2, 7, 8}},
+		"multibyte":       {raw: "&eacute;t&eacute;", text: "été", source: "&eacute;t&eacute;", offsets: []int{0, -1, 8, 9, -1, 17}},
+		"numeric":         {raw: "&#77;M-1", text: "MM-1", source: "&#77;M-1", offsets: []int{0, 5, 6, 7, 8}},
+		"not escapable":   {raw: "a\\b", text: "a\\b", source: "a\\b"},
+		"unknown entity":  {raw: "a &nope; b", text: "a &nope; b", source: "a &nope; b"},
+		"escaped escape":  {raw: "\\\\-1", text: "\\-1", source: "\\\\-1", offsets: []int{0, 2, 3, 4}},
+		"stops at length": {raw: "a&amp;b&amp;", text: "a&b", source: "a&amp;b", offsets: []int{0, 1, 6, 7}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The second match starts inside the reference of é, and cannot be replaced in the source.
+			source, ok := unescapeText(tc.raw, tc.text)
+			require.True(t, ok)
+			assert.Equal(t, tc.text, source.text)
+			assert.Equal(t, tc.source, source.raw)
+			assert.Equal(t, tc.offsets, source.offsets)
+		})
+	}
+
+	_, ok := unescapeText("AT&amp;T", "AT&amp;T")
+	assert.False(t, ok)
+}
+
+func TestEscapedTextReplace(t *testing.T) {
+	// The second match starts inside the reference of é, and cannot be replaced in the source.
+	source, ok := unescapeText("&eacute;quipe MM\\-12, AT&amp;T", "équipe MM-12, AT&T")
+	require.True(t, ok)
+
+	matches := []*Match{
+		{Start: 0, End: 2, Replacement: "e"},
+		{Start: 1, End: 7, Replacement: "[team](https://example.com)"},
+		{Start: 8, End: 13, Replacement: "[MM-12](https://example.com/MM-12)"},
+	}
+	aligned := source.aligned(matches)
+	assert.Equal(t, []*Match{matches[0], matches[2]}, aligned)
+
+	replaced := source.replace(aligned)
+	assert.Equal(t, "equipe [MM-12](https://example.com/MM-12), AT&T", replaced.text)
+	assert.Equal(t, "equipe [MM-12](https://example.com/MM-12), AT&amp;T", replaced.raw)
+
+	// Later links are matched and replaced in the replaced text.
+	at := len("equipe [MM-12](https://example.com/MM-12), ")
+	replaced = replaced.replace([]*Match{{Start: at, End: at + len("AT&T"), Replacement: "[AT&T](https://att.com)"}})
+	assert.Equal(t, "equipe [MM-12](https://example.com/MM-12), [AT&T](https://att.com)", replaced.raw)
+}
+
+func TestEscapedCaptures(t *testing.T) {
+	p, _ := setupStorageTest(t, &Configuration{Links: []*Link{{
+		Name:     "tag",
+		Pattern:  "tag:(?P<tag>\\S+)",
+		Template: "[$tag](https://example.com/tags/$tag) `$tag`",
+	}}})
+
+	// Captures are taken from the unescaped text, and escaped again where they are inserted.
+	assert.Equal(t, "[a\\*b\\*](https://example.com/tags/a%2Ab%2A) `a*b*`", linkedMessage(p, "tag:a\\*b\\*"))
+	assert.Equal(t, "[\\<c\\>](https://example.com/tags/%3Cc%3E) `<c>`", linkedMessage(p, "tag:&lt;c&gt;"))
+	assert.Equal(t, "[d/e?f](https://example.com/tags/d/e?f) `d/e?f`", linkedMessage(p, "tag:d/e?f"))
+}
+
+func TestUnescapedTemplates(t *testing.T) {
+	p, _ := setupStorageTest(t, &Configuration{Links: []*Link{{
+		Name:     "jira",
+		Pattern:  "MM-(?P<id>\\d+)",
+		Template: "https://mattermost.atlassian.net/browse/MM-${id}",
+	}, {
+		Name:     "word",
+		Pattern:  "w:(?P<w>\\w+)",
+		Template: "${w}",
+	}, {
+		Name:     "pr",
+		Pattern:  "PR-(?P<id>\\d+)",
+		Template: "<https://github.com/mattermost/mattermost-server/pull/${id}>",
+	}}})
+
+	// Groups outside the label and destination of a link are inserted as they are.
+	assert.Equal(t, "https://mattermost.atlassian.net/browse/MM-12", linkedMessage(p, "MM-12"))
+	assert.Equal(t, "foo_bar", linkedMessage(p, "w:foo_bar"))
+	assert.Equal(t, "<https://github.com/mattermost/mattermost-server/pull/34>", linkedMessage(p, "PR-34"))
+}
+
+func TestSplitTemplate(t *testing.T) {
+	template := "[$id](https://example.com/$id) ![$id]($id) `$id` https://example.com/$id"
+	var kinds []string
+	for _, part := range splitTemplate(template) {
+		switch {
+		case part.escape == nil:
+			kinds = append(kinds, "markup "+part.template)
+		case part.escape("(") == "%28":
+			kinds = append(kinds, "destination "+part.template)
+		default:
+			kinds = append(kinds, "text "+part.template)
+		}
+	}
+	assert.Equal(t, []string{
+		"markup [", "text $id", "markup ](", "destination https://example.com/$id", "markup ) ![",
+		"text $id", "markup ](", "destination $id", "markup ) `$id` https://example.com/$id",
+	}, kinds)
+}
@@ -0,0 +1,154 @@
+package autolink
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the expected output of the files in testdata/golden")
+
+// goldenFile is a case of the rewrite corpus in testdata/golden. It holds a plugin
+// configuration and messages as posted and as saved by the plugin, in sections:
+//
+//	What the case is about.
+//	-- config.json --
+//	{"Links": [{"Pattern": "(Mattermost)", "Template": "[Mattermost](https://mattermost.com)"}]}
+//	-- input --
+//	Welcome to Mattermost!
+//	-- output --
+//	Welcome to [Mattermost](https://mattermost.com)!
+//
+// Any number of input and output pairs may follow the configuration. An input without output
+// expects an empty message, and gets its output with -update. Every section ends with a
+// newline that is not part of its content.
+type goldenFile struct {
+	comment string
+	config  string
+	cases   []*goldenCase
+}
+
+type goldenCase struct {
+	input, output string
+}
+
+var goldenSectionMarker = regexp.MustCompile(`^-- (.+) --$`)
+
+func parseGoldenFile(data string) (*goldenFile, error) {
+	type section struct {
+		name    string
+		content string
+	}
+	var sections []*section
+	current := &section{}
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if m := goldenSectionMarker.FindStringSubmatch(strings.TrimSuffix(line, "\n")); m != nil {
+			sections = append(sections, current)
+			current = &section{name: m[1]}
+			continue
+		}
+		current.content += line
+	}
+	sections = append(sections, current)
+
+	f := &goldenFile{comment: sections[0].content}
+	answered := 0
+	for _, s := range sections[1:] {
+		content := strings.TrimSuffix(s.content, "\n")
+		switch {
+		case s.name == "config.json" && f.config == "" && len(f.cases) == 0:
+			f.config = content
+		case s.name == "input":
+			f.cases = append(f.cases, &goldenCase{input: content})
+		case s.name == "output" && answered < len(f.cases):
+			f.cases[len(f.cases)-1].output = content
+			answered = len(f.cases)
+		default:
+			return nil, fmt.Errorf("unexpected section %q", s.name)
+		}
+	}
+	if f.config == "" {
+		return nil, fmt.Errorf("missing config.json section")
+	}
+	if len(f.cases) == 0 {
+		return nil, fmt.Errorf("missing input section")
+	}
+	return f, nil
+}
+
+func (f *goldenFile) String() string {
+	s := f.comment + "-- config.json --\n" + f.config + "\n"
+	for _, c := range f.cases {
+		s += "-- input --\n" + c.input + "\n-- output --\n" + c.output + "\n"
+	}
+	return s
+}
+
+// TestGolden posts the input messages of every file in testdata/golden with the plugin
+// configured from the file, and compares the saved messages with the expected output. Run
+// with -update to rewrite the expected output instead.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.txt"))
+	require.Nil(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			require.Nil(t, err)
+			f, err := parseGoldenFile(string(data))
+			require.Nil(t, err)
+
+			p := setupGoldenPlugin(t, f.config)
+			for i, c := range f.cases {
+				post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{
+					UserId:    "user",
+					ChannelId: "channel",
+					Message:   c.input,
+				})
+				if *updateGolden {
+					c.output = post.Message
+					continue
+				}
+				assert.Equal(t, c.output, post.Message, "input %d", i+1)
+			}
+
+			if *updateGolden {
+				require.Nil(t, ioutil.WriteFile(path, []byte(f.String()), 0644))
+			}
+		})
+	}
+}
+
+// setupGoldenPlugin configures the plugin for a golden file. Posts are made in the public
+// channel town-square of the team test.
+func setupGoldenPlugin(t *testing.T, config string) *Plugin {
+	var conf Configuration
+	require.Nil(t, json.Unmarshal([]byte(config), &conf), "invalid config.json")
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("GetChannel", "channel").Return(&model.Channel{Id: "channel", TeamId: "team", Name: "town-square", Type: model.CHANNEL_OPEN}, nil)
+	api.On("GetTeam", "team").Return(&model.Team{Id: "team", Name: "test"}, nil)
+	mockKVStore(api)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+	return p
+}
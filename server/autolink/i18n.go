@@ -0,0 +1,96 @@
+package autolink
+
+import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/nicksnyder/go-i18n/i18n/bundle"
+)
+
+// defaultLocale is used when the locale of a user has no translations.
+const defaultLocale = "en"
+
+// translationBundles are embedded in the plugin, so that it needs no files besides its
+// executable. Every bundle maps the message IDs to go-i18n templates.
+var translationBundles = map[string]map[string]string{
+	"en": translationsEN,
+	"de": translationsDE,
+	"ja": translationsJA,
+}
+
+var translations = loadTranslations()
+
+// defaultT translates to the default locale. It is used for logs and the Error method of
+// localized errors.
+var defaultT = i18n.TranslateFunc(translations.MustTfunc(defaultLocale))
+
+func loadTranslations() *bundle.Bundle {
+	b := bundle.New()
+	for locale, messages := range translationBundles {
+		standard := make([]map[string]string, 0, len(messages))
+		for id, translation := range messages {
+			standard = append(standard, map[string]string{"id": id, "translation": translation})
+		}
+		data, err := json.Marshal(standard)
+		if err != nil {
+			panic(err)
+		}
+		if err := b.ParseTranslationFileBytes(locale+".json", data); err != nil {
+			panic(err)
+		}
+	}
+	return b
+}
+
+// translationFunc returns the function translating messages to the locale of the user,
+// falling back to the default locale.
+func (p *Plugin) translationFunc(userID string) i18n.TranslateFunc {
+	locale := defaultLocale
+	if user, appErr := p.API.GetUser(userID); appErr == nil && user.Locale != "" {
+		locale = user.Locale
+	}
+
+	T, err := translations.Tfunc(locale, defaultLocale)
+	if err != nil {
+		mlog.Warn("Failed to load translations: "+err.Error(), mlog.String("locale", locale))
+		return defaultT
+	}
+	return i18n.TranslateFunc(T)
+}
+
+// localizedError is an error whose message can be translated to the locale of a user.
+type localizedError struct {
+	id     string
+	params map[string]interface{}
+}
+
+func newLocalizedError(id string, params map[string]interface{}) error {
+	return &localizedError{id: id, params: params}
+}
+
+func (e *localizedError) Error() string {
+	return e.translate(defaultT)
+}
+
+// translate returns the message of the error in the language of T. Parameters that are
+// errors themselves are translated too.
+func (e *localizedError) translate(T i18n.TranslateFunc) string {
+	params := make(map[string]interface{}, len(e.params))
+	for name, value := range e.params {
+		if err, ok := value.(error); ok {
+			value = translateError(T, err)
+		}
+		params[name] = value
+	}
+	return T(e.id, params)
+}
+
+// translateError returns the message of the error in the language of T. Errors that are not
+// localized, such as those of the regexp package, are returned as is.
+func translateError(T i18n.TranslateFunc, err error) string {
+	if e, ok := err.(*localizedError); ok {
+		return e.translate(T)
+	}
+	return err.Error()
+}
@@ -0,0 +1,80 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslationBundlesComplete(t *testing.T) {
+	for locale, messages := range translationBundles {
+		for id := range translationsEN {
+			assert.Contains(t, messages, id, "%s is missing %s", locale, id)
+		}
+		for id := range messages {
+			assert.Contains(t, translationsEN, id, "%s has unknown %s", locale, id)
+		}
+	}
+}
+
+func TestLocalizedCommand(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "cve",
+			Pattern:  "CVE-(?P<year>\\d{4})-(?P<id>\\d+)",
+			Template: "[CVE-${year}-${id}](https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-${year}-${id})",
+			Validators: map[string]*Validator{
+				"year": {Min: int64Ptr(1999)},
+			},
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("HasPermissionTo", mock.AnythingOfType("string"), model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", "de").Return(&model.User{Locale: "de"}, nil)
+	api.On("GetUser", "ja").Return(&model.User{Locale: "ja"}, nil)
+	api.On("GetUser", "fr").Return(&model.User{Locale: "fr"}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "de", Command: "/autolink test CVE-0000-1"})
+	assert.Equal(t, "Die Nachricht würde so gesendet:\n```\nCVE-0000-1\n```\n"+
+		"* **cve**: `CVE-0000-1` wurde nicht verlinkt, year: \"0000\" ist kleiner als 1999.", resp.Text)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "ja", Command: "/autolink revert link cve yesterday"})
+	assert.Equal(t, "投稿を復元できませんでした: 無効な時刻 \"yesterday\"。"+translationsJA["autolink.command.revert.usage"], resp.Text)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "fr", Command: "/autolink test"})
+	assert.Equal(t, "Usage: `/autolink test <message>`.", resp.Text)
+}
+
+func TestLocalizedError(t *testing.T) {
+	_, err := NewAutoLinker(&Link{
+		Pattern:    "(?P<id>\\d+)",
+		Template:   "${id}",
+		Conditions: &Conditions{ChannelNamePattern: "("},
+	}, nil)
+	require.NotNil(t, err)
+
+	assert.Equal(t, "ChannelNamePattern: error parsing regexp: missing closing ): `(`", err.Error())
+
+	de := i18n.TranslateFunc(translations.MustTfunc("de"))
+	assert.Equal(t, "ChannelNamePattern: error parsing regexp: missing closing ): `(`", translateError(de, err))
+
+	_, err = NewAutoLinker(&Link{Type: "glob", Pattern: "x", Template: "y"}, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, "unknown link type \"glob\"", err.Error())
+	assert.Equal(t, "unbekannter Link-Typ \"glob\"", translateError(de, err))
+}
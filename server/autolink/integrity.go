@@ -0,0 +1,125 @@
+package autolink
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost-server/utils/markdown"
+)
+
+// markdownStructure places every byte of a message in the structure of the message: the
+// blocks containing it, by position and type, and the inline nodes containing it, by type.
+// Bytes of text and code blocks have a place, while markup, code spans and link
+// destinations have none.
+//
+// Comparing the structure of a message before and after replacing the text of a text node
+// shows whether the replacement changed how the rest of the message parses, such as a
+// template leaving a code span or a link open.
+type markdownStructure []string
+
+func parseMarkdownStructure(message string) markdownStructure {
+	structure := make(markdownStructure, len(message))
+	place := func(r markdown.Range, path string) {
+		for i := r.Position; i < r.End && i < len(structure); i++ {
+			structure[i] = path
+		}
+	}
+
+	// Blocks are identified by their position among their siblings, so that splitting or
+	// merging blocks shows, while inline nodes are only identified by their type, since
+	// replacements add links next to the text.
+	path := []string{""}
+	children := []int{0}
+	markdown.Inspect(message, func(node interface{}) bool {
+		if node == nil {
+			path = path[:len(path)-1]
+			children = children[:len(children)-1]
+			return true
+		}
+
+		name := fmt.Sprintf("%T", node)
+		if _, ok := node.(markdown.Inline); !ok {
+			name = fmt.Sprintf("%d:%s", children[len(children)-1], name)
+			children[len(children)-1]++
+		}
+		path = append(path, path[len(path)-1]+"/"+name)
+		children = append(children, 0)
+
+		switch v := node.(type) {
+		case *markdown.Text:
+			place(v.Range, path[len(path)-1])
+		case *markdown.FencedCode:
+			for _, line := range v.RawCode {
+				place(line.Range, path[len(path)-1])
+			}
+		case *markdown.IndentedCode:
+			for _, line := range v.RawCode {
+				place(line.Range, path[len(path)-1])
+			}
+		}
+		return true
+	})
+	return structure
+}
+
+// replacedRange is a range of a message, from start to end, replaced with text of size bytes.
+type replacedRange struct {
+	start, end, size int
+}
+
+// sameOutside reports whether the bytes outside of the ranges of the message of s have the
+// same place in other, where the ranges were replaced. The ranges are in order and do not
+// overlap.
+func (s markdownStructure) sameOutside(other markdownStructure, ranges []replacedRange) bool {
+	shift := 0
+	for _, r := range ranges {
+		shift += r.size - (r.end - r.start)
+	}
+	if len(s)+shift != len(other) {
+		return false
+	}
+
+	same := func(start, end, shift int) bool {
+		for i := start; i < end; i++ {
+			if s[i] != other[i+shift] {
+				return false
+			}
+		}
+		return true
+	}
+	shift = 0
+	pos := 0
+	for _, r := range ranges {
+		if !same(pos, r.start, shift) {
+			return false
+		}
+		shift += r.size - (r.end - r.start)
+		pos = r.end
+	}
+	return same(pos, len(s), shift)
+}
+
+// structureGuard checks that replacing the text of text nodes keeps the structure of the rest
+// of a message. The structure of the message is only parsed once a replacement needs
+// checking, and again for every replacement.
+type structureGuard struct {
+	message   string
+	structure markdownStructure
+}
+
+// keeps reports whether replacing the range from start to end of the message with text keeps
+// the place of every byte outside of the range.
+func (g *structureGuard) keeps(message string, start, end int, text string) bool {
+	if g.structure == nil || g.message != message {
+		g.message, g.structure = message, parseMarkdownStructure(message)
+	}
+
+	replaced := message[:start] + text + message[end:]
+	structure := parseMarkdownStructure(replaced)
+	if !g.structure.sameOutside(structure, []replacedRange{{start: start, end: end, size: len(text)}}) {
+		return false
+	}
+
+	// The next replacement is most likely made to this message.
+	g.message, g.structure = replaced, structure
+	return true
+}
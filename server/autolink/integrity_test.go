@@ -0,0 +1,126 @@
+package autolink
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructureGuard(t *testing.T) {
+	message := "See MM-1 and `code`.\n\n```\nMM-2\n```"
+	start, end := 0, len("See MM-1 and ")
+
+	for name, tc := range map[string]struct {
+		text     string
+		expected bool
+	}{
+		"link":             {"See [MM-1](https://example.com) and ", true},
+		"emphasis":         {"See **MM-1** and ", true},
+		"code span opened": {"See `MM-1 and ", false},
+		"link opened":      {"See [MM-1 and ", true},
+		"fence opened":     {"See\n```\nMM-1 and ", false},
+		"new paragraph":    {"See\n\nMM-1 and ", false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			guard := &structureGuard{}
+			assert.Equal(t, tc.expected, guard.keeps(message, start, end, tc.text))
+		})
+	}
+}
+
+func TestReplaceLinksKeepsStructure(t *testing.T) {
+	links := compileLinks(t, &Link{
+		Name:     "code",
+		Pattern:  "(?P<id>MM-\\d+)",
+		Template: "`$id",
+	}, &Link{
+		Name:     "fence",
+		Pattern:  "(?P<id>PR-\\d+)",
+		Template: "\n```\n$id",
+	}, &Link{
+		Name:     "jira",
+		Pattern:  "(?P<id>MM-\\d+)",
+		Template: "[$id](https://mattermost.atlassian.net/browse/$id)",
+	}, &Link{
+		Name:     "support",
+		Pattern:  "(?P<id>CS-\\d+)",
+		Template: "[$id](https://support.example.com/$id)",
+	})
+
+	var matchers []*countingMatcher
+	for _, l := range links {
+		matcher := &countingMatcher{Matcher: l.matcher}
+		l.matcher = matcher
+		matchers = append(matchers, matcher)
+	}
+
+	p := &Plugin{}
+	p.configuration.Store(&Configuration{})
+	result := p.replaceLinks("MM-1 and PR-2 see CS-3 `x`.", links, newProcessingBudget(&Configuration{}))
+	require.Nil(t, result.exhaustedBy)
+	assert.Equal(t, "MM-1 and PR-2 see [CS-3](https://support.example.com/CS-3) `x`.", result.message)
+	assert.Equal(t, []*AutoLinker{links[3]}, result.applied)
+	assert.Equal(t, []*AutoLinker{links[0], links[1]}, result.discarded)
+	require.Len(t, result.entities, 1)
+	assert.Equal(t, "support", result.entities[0].Link)
+
+	// The links are not matched again to leave out the replacements, only once for each of
+	// the texts around the code span, so the text these matched stays unlinked rather than
+	// being linked by a later link.
+	for _, matcher := range matchers {
+		assert.Equal(t, 2, matcher.calls)
+	}
+
+	// Replacements that keep the structure are not checked one link at a time.
+	result = p.replaceLinks("MM-1 and `MM-3`", links[2:3], newProcessingBudget(&Configuration{}))
+	assert.Empty(t, result.discarded)
+	assert.Len(t, result.applied, 1)
+}
+
+func TestRelink(t *testing.T) {
+	code, support, quote := &AutoLinker{}, &AutoLinker{}, &AutoLinker{}
+	source := &escapedText{text: "a MM-1 b CS-3", raw: "a MM-1 b CS-3"}
+	stage := func(l *AutoLinker, text string, matches ...*Match) *linkStage {
+		s := &linkStage{link: l, text: text, matches: matches}
+		for _, m := range matches {
+			s.entities = append(s.entities, &Entity{Text: m.Text})
+		}
+		return s
+	}
+	stages := []*linkStage{
+		stage(code, "a `MM-1 b CS-3", &Match{Start: 2, End: 6, Text: "MM-1", Replacement: "`MM-1"}),
+		stage(support, "a `MM-1 b [CS-3](x)", &Match{Start: 10, End: 14, Text: "CS-3", Replacement: "[CS-3](x)"}),
+		// A match in the replacement of a link that is left out is dropped.
+		stage(quote, "a > b [CS-3](x)", &Match{Start: 2, End: 7, Text: "`MM-1", Replacement: ">"}),
+	}
+
+	linked := relink(source, stages, func(raw string) bool { return !strings.Contains(raw, "`") })
+	assert.Equal(t, "a MM-1 b [CS-3](x)", linked.text)
+	assert.Equal(t, []*AutoLinker{support}, linked.applied)
+	assert.Equal(t, []*AutoLinker{code}, linked.discarded)
+	require.Len(t, linked.entities, 1)
+	assert.Equal(t, "CS-3", linked.entities[0].Text)
+	require.Len(t, linked.stages, 1)
+	assert.Equal(t, 9, linked.stages[0].matches[0].Start)
+
+	// Applying all of them again gives the same text.
+	linked = relink(source, stages, func(string) bool { return true })
+	assert.Equal(t, "a > b [CS-3](x)", linked.text)
+	assert.Len(t, linked.applied, 3)
+}
+
+func TestSameOutside(t *testing.T) {
+	message := "See MM-1, `code` and MM-2."
+	structure := parseMarkdownStructure(message)
+	first := replacedRange{start: 4, end: 8, size: len("[MM-1](x)")}
+	second := replacedRange{start: 21, end: 25, size: len("[MM-2](x)")}
+
+	linked := parseMarkdownStructure("See [MM-1](x), `code` and [MM-2](x).")
+	assert.True(t, structure.sameOutside(linked, []replacedRange{first, second}))
+	assert.False(t, structure.sameOutside(linked, []replacedRange{first}))
+
+	opened := parseMarkdownStructure("See `MM-1, `code` and [MM-2](x).")
+	assert.False(t, structure.sameOutside(opened, []replacedRange{{start: 4, end: 8, size: 5}, second}))
+}
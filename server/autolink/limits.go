@@ -0,0 +1,152 @@
+package autolink
+
+import (
+	"regexp/syntax"
+	"time"
+)
+
+// Defaults for the resource limits used when the configuration leaves them unset.
+const (
+	defaultMaxPatternLength       = 1000
+	defaultMaxProgramSize         = 10000
+	defaultMaxCaptureGroups       = 32
+	defaultMaxPostProcessingTime  = 500 * time.Millisecond
+	defaultMaxPostProcessingBytes = 16 * 1024 * 1024
+	defaultSlowLinkThreshold      = 100 * time.Millisecond
+)
+
+// limit returns value if it was configured, or def otherwise.
+func limit(value, def int) int {
+	if value <= 0 {
+		return def
+	}
+	return value
+}
+
+func (c *Configuration) maxPatternLength() int {
+	if c == nil {
+		return defaultMaxPatternLength
+	}
+	return limit(c.MaxPatternLength, defaultMaxPatternLength)
+}
+
+func (c *Configuration) maxProgramSize() int {
+	if c == nil {
+		return defaultMaxProgramSize
+	}
+	return limit(c.MaxProgramSize, defaultMaxProgramSize)
+}
+
+func (c *Configuration) maxCaptureGroups() int {
+	if c == nil {
+		return defaultMaxCaptureGroups
+	}
+	return limit(c.MaxCaptureGroups, defaultMaxCaptureGroups)
+}
+
+func (c *Configuration) maxPostProcessingTime() time.Duration {
+	if c == nil || c.MaxPostProcessingMilliseconds <= 0 {
+		return defaultMaxPostProcessingTime
+	}
+	return time.Duration(c.MaxPostProcessingMilliseconds) * time.Millisecond
+}
+
+func (c *Configuration) maxPostProcessingBytes() int {
+	if c == nil {
+		return defaultMaxPostProcessingBytes
+	}
+	return limit(c.MaxPostProcessingBytes, defaultMaxPostProcessingBytes)
+}
+
+func (c *Configuration) slowLinkThreshold() time.Duration {
+	if c == nil || c.SlowLinkMilliseconds <= 0 {
+		return defaultSlowLinkThreshold
+	}
+	return time.Duration(c.SlowLinkMilliseconds) * time.Millisecond
+}
+
+// checkPatternLimits verifies that a link's pattern stays within the configured limits. The
+// length and capture groups are checked against the pattern as written by the admin, the program
+// size against the full pattern including the prefix and suffix groups.
+func checkPatternLimits(link *Link, pattern string, conf *Configuration) error {
+	if max := conf.maxPatternLength(); len(link.Pattern) > max {
+		return newLocalizedError("autolink.link.pattern_length", map[string]interface{}{"Length": len(link.Pattern), "Limit": max})
+	}
+
+	re, err := syntax.Parse(link.Pattern, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	if max := conf.maxCaptureGroups(); re.MaxCap() > max {
+		return newLocalizedError("autolink.link.capture_groups", map[string]interface{}{"Groups": re.MaxCap(), "Limit": max})
+	}
+
+	full, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	prog, err := syntax.Compile(full.Simplify())
+	if err != nil {
+		return err
+	}
+	if max := conf.maxProgramSize(); len(prog.Inst) > max {
+		return newLocalizedError("autolink.link.program_size", map[string]interface{}{"Instructions": len(prog.Inst), "Limit": max})
+	}
+
+	return nil
+}
+
+// processingBudget bounds the time and the number of bytes scanned while processing a post.
+type processingBudget struct {
+	deadline  time.Time
+	bytesLeft int
+
+	// lookupDeadline bounds the time spent waiting for lookups, which is not counted
+	// against the deadline.
+	lookupDeadline time.Time
+
+	// elapsed holds the time spent applying every link, lookups included.
+	elapsed map[*AutoLinker]time.Duration
+
+	// trace records how the post is linked, if it is traced.
+	trace *postTrace
+}
+
+func newProcessingBudget(conf *Configuration) *processingBudget {
+	now := time.Now()
+	return &processingBudget{
+		deadline:       now.Add(conf.maxPostProcessingTime()),
+		bytesLeft:      conf.maxPostProcessingBytes(),
+		lookupDeadline: now.Add(maxLookupTimePerPost),
+		elapsed:        make(map[*AutoLinker]time.Duration),
+	}
+}
+
+// spend records that n bytes were scanned and reports whether the budget is still available.
+func (b *processingBudget) spend(n int) bool {
+	b.bytesLeft -= n
+	return b.bytesLeft >= 0 && !b.expired()
+}
+
+// expired reports whether the time of the budget ran out.
+func (b *processingBudget) expired() bool {
+	return !time.Now().Before(b.deadline)
+}
+
+// lookupTimeout returns the time a lookup may take, at most timeout.
+func (b *processingBudget) lookupTimeout(timeout time.Duration) time.Duration {
+	if left := time.Until(b.lookupDeadline); left < timeout {
+		return left
+	}
+	return timeout
+}
+
+// charge records that d was spent applying the link.
+func (b *processingBudget) charge(l *AutoLinker, d time.Duration) {
+	b.elapsed[l] += d
+}
+
+// wait records that d was spent waiting for a lookup.
+func (b *processingBudget) wait(d time.Duration) {
+	b.deadline = b.deadline.Add(d)
+}
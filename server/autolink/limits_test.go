@@ -0,0 +1,133 @@
+package autolink
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternLimits(t *testing.T) {
+	var tests = []struct {
+		Link          *Link
+		Configuration *Configuration
+		expectedError string
+	}{
+		{
+			&Link{Pattern: "(Mattermost)", Template: "x"},
+			nil,
+			"",
+		}, {
+			&Link{Pattern: strings.Repeat("a", defaultMaxPatternLength+1), Template: "x"},
+			nil,
+			"pattern is 1001 characters long, the limit is 1000",
+		}, {
+			&Link{Pattern: "(Mattermost)", Template: "x"},
+			&Configuration{MaxPatternLength: 5},
+			"pattern is 12 characters long, the limit is 5",
+		}, {
+			&Link{Pattern: "(a)(b)(c)", Template: "x"},
+			&Configuration{MaxCaptureGroups: 2},
+			"pattern has 3 capture groups, the limit is 2",
+		}, {
+			&Link{Pattern: "(a)(b)", Template: "x"},
+			&Configuration{MaxCaptureGroups: 2},
+			"",
+		}, {
+			&Link{Pattern: "a{1000}", Template: "x"},
+			nil,
+			"",
+		}, {
+			&Link{Pattern: "(a{100}){5}", Template: "x"},
+			&Configuration{MaxProgramSize: 100},
+			"compiled pattern has",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := NewAutoLinker(tt.Link, tt.Configuration)
+		if tt.expectedError == "" {
+			assert.Nil(t, err, tt.Link.Pattern)
+		} else if assert.NotNil(t, err, tt.Link.Pattern) {
+			assert.Contains(t, err.Error(), tt.expectedError)
+		}
+	}
+}
+
+func TestProcessingBudget(t *testing.T) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.OnConfigurationChange()
+
+	post := &model.Post{Message: "Welcome to Mattermost!"}
+	rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+	assert.Equal(t, "Welcome to [Mattermost](https://mattermost.com)!", rpost.Message)
+
+	conf.MaxPostProcessingBytes = 100
+	p.OnConfigurationChange()
+
+	message := strings.Repeat("Mattermost\n\n", 20)
+	post = &model.Post{Message: message}
+	rpost, _ = p.MessageWillBePosted(&plugin.Context{}, post)
+	assert.Equal(t, message, rpost.Message)
+}
+
+// countingMatcher counts the calls to the Matcher it wraps.
+type countingMatcher struct {
+	Matcher
+	calls int
+}
+
+func (m *countingMatcher) FindAll(message string) []*Submatch {
+	m.calls++
+	return m.Matcher.FindAll(message)
+}
+
+func TestProcessingBudgetBeforeMatching(t *testing.T) {
+	links := compileLinks(t, &Link{
+		Name:     "mattermost",
+		Pattern:  "(Mattermost)",
+		Template: "[Mattermost](https://mattermost.com)",
+	})
+	matcher := &countingMatcher{Matcher: links[0].matcher}
+	links[0].matcher = matcher
+
+	p := &Plugin{}
+	source := &escapedText{text: "Welcome to Mattermost!", raw: "Welcome to Mattermost!"}
+
+	// A spent budget stops the link before it matches anything.
+	budget := newProcessingBudget(&Configuration{MaxPostProcessingBytes: 10})
+	linked := p.linkText(source, links, budget)
+	assert.Equal(t, links[0], linked.exhaustedBy)
+	assert.Equal(t, 0, matcher.calls)
+
+	budget = newProcessingBudget(&Configuration{})
+	budget.deadline = time.Now()
+	linked = p.linkText(source, links, budget)
+	assert.Equal(t, links[0], linked.exhaustedBy)
+	assert.Equal(t, 0, matcher.calls)
+
+	linked = p.linkText(source, links, newProcessingBudget(&Configuration{}))
+	assert.Nil(t, linked.exhaustedBy)
+	assert.Equal(t, "Welcome to [Mattermost](https://mattermost.com)!", linked.text)
+	assert.Equal(t, 1, matcher.calls)
+}
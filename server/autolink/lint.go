@@ -0,0 +1,386 @@
+package autolink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// Severities of the issues found by autolink-lint.
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+)
+
+// lintIssue is a problem found in a link of the configuration.
+type lintIssue struct {
+	// Index is the position of the link in the configuration, starting at 0.
+	Index    int
+	Link     string
+	Severity string
+	Check    string
+	Message  string
+}
+
+// lintMessage is a sample message as the plugin would save it.
+type lintMessage struct {
+	Input    string
+	Output   string
+	Links    []string  `json:",omitempty"`
+	Entities []*Entity `json:",omitempty"`
+}
+
+// lintReport is the output of autolink-lint.
+type lintReport struct {
+	Version    int
+	Migrations []string `json:",omitempty"`
+	Issues     []*lintIssue
+	Messages   []*lintMessage `json:",omitempty"`
+}
+
+func (r *lintReport) count(severity string) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// RunLint is the autolink-lint command. It checks the links of a configuration file the way
+// the plugin compiles them, and optionally links a file of sample messages with them. It
+// returns the exit code: 0 when no errors were found, 1 when some were, and 2 when the
+// command could not run.
+func RunLint(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("autolink-lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: autolink-lint [flags] <config.json | links.json>")
+		flags.PrintDefaults()
+	}
+	messagesPath := flags.String("messages", "", "file of sample messages to link, one per line, or a JSON array of messages if it ends in .json")
+	jsonOutput := flags.Bool("json", false, "write the report as JSON")
+	strict := flags.Bool("strict", false, "exit with 1 on warnings too")
+	channelName := flags.String("channel", "town-square", "name of the channel the sample messages are posted in, for link conditions")
+	channelType := flags.String("channel-type", model.CHANNEL_OPEN, "type of the channel the sample messages are posted in: O, P, D or G")
+	teamName := flags.String("team", "", "name of the team the sample messages are posted in, for link conditions")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	c, err := loadLintConfiguration(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+
+	report := &lintReport{}
+	if report.Migrations, err = migrateConfiguration(c); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+	report.Version = c.Version
+
+	links, issues := lintConfiguration(c)
+	report.Issues = issues
+
+	if *messagesPath != "" {
+		messages, err := readLintMessages(*messagesPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		channel := &model.Channel{Id: "channel", Name: *channelName, Type: *channelType}
+		var team *model.Team
+		if *teamName != "" {
+			channel.TeamId = "team"
+			team = &model.Team{Id: "team", Name: *teamName}
+		}
+		report.Messages = lintMessages(c, links, messages, channel, team)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		writeLintReport(stdout, report)
+	}
+
+	if report.count(lintError) > 0 || (*strict && report.count(lintWarning) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// loadLintConfiguration reads the plugin configuration from the server's config.json, from a
+// plugin configuration on its own, or from a JSON array of links.
+func loadLintConfiguration(data []byte) (*Configuration, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var links []*Link
+		if err := json.Unmarshal(data, &links); err != nil {
+			return nil, err
+		}
+		return &Configuration{Links: links}, nil
+	}
+
+	var server struct {
+		PluginSettings *struct {
+			Plugins map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(data, &server); err != nil {
+		return nil, err
+	}
+	if server.PluginSettings != nil {
+		settings, ok := server.PluginSettings.Plugins[manifest.Id]
+		if !ok {
+			return nil, fmt.Errorf("no settings for the plugin %s in PluginSettings.Plugins", manifest.Id)
+		}
+		data = settings
+	}
+
+	var c Configuration
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// lintConfiguration compiles the links of the configuration and checks their templates and
+// how they overlap. It returns the links that compiled, to link sample messages with.
+func lintConfiguration(c *Configuration) ([]*AutoLinker, []*lintIssue) {
+	var links []*AutoLinker
+	var issues []*lintIssue
+	for i, l := range c.Links {
+		issue := func(severity, check, format string, args ...interface{}) {
+			issues = append(issues, &lintIssue{
+				Index:    i,
+				Link:     l.DisplayName(),
+				Severity: severity,
+				Check:    check,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		al, err := NewAutoLinker(l, c)
+		if err != nil {
+			issue(lintError, "compile", "%s", translateError(defaultT, err))
+			continue
+		}
+		links = append(links, al)
+
+		reported := make(map[string]bool)
+		groups := make(map[string]bool)
+		for _, group := range al.matcher.Groups() {
+			groups[group] = false
+		}
+		// Templates may refer to the prefix and suffix groups added around the pattern.
+		if m, ok := al.matcher.(*regexpMatcher); ok {
+			for _, name := range m.pattern.SubexpNames() {
+				if name == prefixGroup || name == suffixGroup {
+					groups[name] = true
+				}
+			}
+		}
+		for _, template := range linkTemplates(l) {
+			for _, name := range templateGroupReferences(template) {
+				if _, ok := groups[name]; !ok {
+					if !reported[name] {
+						issue(lintError, "undefined_group", "a template refers to $%s, which is not a group of the pattern", name)
+						reported[name] = true
+					}
+					continue
+				}
+				groups[name] = true
+			}
+		}
+		for name := range l.Templates {
+			groups[name] = true
+		}
+		for name := range l.Validators {
+			groups[name] = true
+		}
+
+		// Groups of regular expressions are chosen by the author, while other link types
+		// offer groups that are fine to leave unused.
+		if _, ok := al.matcher.(*regexpMatcher); ok {
+			for _, group := range al.matcher.Groups() {
+				if !groups[group] {
+					issue(lintWarning, "unused_group", "the named group %q is not used by any template or validator", group)
+				}
+			}
+		}
+	}
+
+	index := make(map[*Link]int, len(c.Links))
+	for i, l := range c.Links {
+		index[l] = i
+	}
+	for _, o := range analyzeOverlaps(links) {
+		issues = append(issues, &lintIssue{
+			Index:    index[o.Link.link],
+			Link:     o.Link.link.DisplayName(),
+			Severity: lintWarning,
+			Check:    o.Kind,
+			Message:  o.warning().Error(),
+		})
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Index < issues[j].Index
+	})
+	return links, issues
+}
+
+// linkTemplates returns the templates of the link that are expanded with the groups of a
+// match, in a stable order.
+func linkTemplates(l *Link) []string {
+	templates := []string{l.Template}
+
+	var names []string
+	for name := range l.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		templates = append(templates, l.Templates[name])
+	}
+
+	if l.Lookup != nil {
+		templates = append(templates, l.Lookup.URL, l.Lookup.Template)
+	}
+	return templates
+}
+
+// templateGroupReferences returns the names of the groups the template refers to as $name or
+// ${name}, once each and in order. Numbered groups, which refer to groups of the pattern by
+// position, are left out.
+func templateGroupReferences(template string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			return names
+		}
+		template = template[i+1:]
+		if strings.HasPrefix(template, "$") {
+			template = template[1:]
+			continue
+		}
+
+		name, rest, ok := extractGroupName(template)
+		if !ok {
+			continue
+		}
+		template = rest
+		if strings.Trim(name, "0123456789") == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+}
+
+// readLintMessages reads the sample messages, one per line, or as a JSON array of messages if
+// the file name ends in .json, so that messages can span several lines. Empty lines are
+// skipped.
+func readLintMessages(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	if filepath.Ext(path) == ".json" {
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return messages, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, model.POST_MESSAGE_MAX_BYTES_V2)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			messages = append(messages, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return messages, nil
+}
+
+// lintMessages links the messages as MessageWillBePosted would in the channel. Lookups are
+// not made, so their links use the link's template.
+func lintMessages(c *Configuration, links []*AutoLinker, messages []string, channel *model.Channel, team *model.Team) []*lintMessage {
+	p := &Plugin{}
+	p.configuration.Store(c)
+
+	var results []*lintMessage
+	for _, message := range messages {
+		post := &model.Post{ChannelId: channel.Id, Message: message}
+		pc := &postContext{
+			post:          post,
+			channel:       channel,
+			channelLoaded: true,
+			team:          team,
+			teamLoaded:    true,
+		}
+
+		linked := &lintMessage{Input: message, Output: message}
+		if result := p.linkMessage(post, linksHolding(pc, links), newProcessingBudget(c)); result != nil && len(result.applied) > 0 {
+			linked.Output = result.message
+			linked.Entities = result.entities
+			for _, l := range result.applied {
+				linked.Links = append(linked.Links, l.link.DisplayName())
+			}
+		}
+		results = append(results, linked)
+	}
+	return results
+}
+
+// writeLintReport writes the report for people: one line per issue, then every sample
+// message as it would be saved.
+func writeLintReport(w io.Writer, report *lintReport) {
+	if len(report.Migrations) > 0 {
+		fmt.Fprintf(w, "configuration migrated to version %d: %s\n", report.Version, strings.Join(report.Migrations, ", "))
+	}
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "links[%d] %s: %s: %s\n", issue.Index, issue.Link, issue.Severity, issue.Message)
+	}
+	for i, m := range report.Messages {
+		if m.Output == m.Input {
+			fmt.Fprintf(w, "message %d: unchanged\n", i+1)
+			continue
+		}
+		fmt.Fprintf(w, "message %d: %q\n", i+1, m.Output)
+	}
+	fmt.Fprintf(w, "%d errors, %d warnings\n", report.count(lintError), report.count(lintWarning))
+}
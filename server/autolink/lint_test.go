@@ -0,0 +1,131 @@
+package autolink
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintConfiguration(t *testing.T) {
+	c := &Configuration{Links: []*Link{{
+		Name:     "jira",
+		Pattern:  "(?P<project>MM)-(?P<jira_id>\\d+)",
+		Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-$jira_id)",
+	}, {
+		Pattern:    "(?P<isbn>\\d{13})",
+		Template:   "[$isbn](https://isbnsearch.org/isbn/$1)",
+		Validators: map[string]*Validator{"isbn": {Checksum: "isbn"}},
+	}, {
+		Name:     "github",
+		Pattern:  "#(?P<issue>\\d+)",
+		Template: "[#$issue](https://github.com/mattermost/mattermost-server/issues/${issue_id}${issue_id})",
+		Lookup: &Lookup{
+			URL:      "https://api.github.com/repos/mattermost/mattermost-server/issues/${number}",
+			Template: "[${lookup:title}](${lookup:html_url})",
+		},
+	}, {
+		Name:       "glossary",
+		Type:       linkTypeDictionary,
+		Dictionary: map[string]string{"DM": "direct message"},
+		Template:   "**$term**",
+	}, {
+		Name:     "broken",
+		Pattern:  "(unclosed",
+		Template: "[x](https://example.com)",
+	}, {
+		Name:     "undefined",
+		Pattern:  "(x)",
+		Template: "${var:site}",
+	}}}
+
+	links, issues := lintConfiguration(c)
+	assert.Len(t, links, 4)
+	assert.Equal(t, []*lintIssue{
+		{Index: 0, Link: "jira", Severity: lintWarning, Check: "unused_group", Message: `the named group "project" is not used by any template or validator`},
+		{Index: 2, Link: "github", Severity: lintError, Check: "undefined_group", Message: "a template refers to $issue_id, which is not a group of the pattern"},
+		{Index: 2, Link: "github", Severity: lintError, Check: "undefined_group", Message: "a template refers to $number, which is not a group of the pattern"},
+		{Index: 4, Link: "broken", Severity: lintError, Check: "compile", Message: "error parsing regexp: missing closing ): `(unclosed`"},
+		{Index: 5, Link: "undefined", Severity: lintError, Check: "compile", Message: `undefined variable "site"`},
+	}, issues)
+}
+
+func TestTemplateGroupReferences(t *testing.T) {
+	assert.Equal(t, []string{"a", "b_c", "d"}, templateGroupReferences("$a ${b_c} $$e $1 ${2} $a $d ${var:x} ${lookup:y} $"))
+	assert.Nil(t, templateGroupReferences("[Mattermost](https://mattermost.com)"))
+}
+
+func TestLoadLintConfiguration(t *testing.T) {
+	c, err := loadLintConfiguration([]byte(`[{"Pattern": "(x)", "Template": "y"}]`))
+	require.Nil(t, err)
+	require.Len(t, c.Links, 1)
+	assert.Equal(t, "(x)", c.Links[0].Pattern)
+
+	c, err = loadLintConfiguration([]byte(`{"Version": 1, "Links": [{"Pattern": "(x)", "Template": "y"}]}`))
+	require.Nil(t, err)
+	assert.Equal(t, 1, c.Version)
+	require.Len(t, c.Links, 1)
+
+	_, err = loadLintConfiguration([]byte(`{"PluginSettings": {"Plugins": {"other": {}}}}`))
+	assert.EqualError(t, err, "no settings for the plugin mattermost-autolink in PluginSettings.Plugins")
+}
+
+func TestRunLint(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := RunLint([]string{"-json", "-messages", "testdata/lint/messages.txt", "testdata/lint/config.json"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stderr.String())
+
+	var report lintReport
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, currentConfigurationVersion(), report.Version)
+	assert.Equal(t, []string{"pin-match-defaults", "number-pattern-groups"}, report.Migrations)
+
+	var checks []string
+	for _, issue := range report.Issues {
+		checks = append(checks, issue.Link+" "+issue.Check)
+	}
+	assert.Equal(t, []string{"jira unused_group", "github undefined_group", "broken compile"}, checks)
+
+	require.Len(t, report.Messages, 3)
+	assert.Equal(t, "See [MM-12](https://mattermost.atlassian.net/browse/MM-12) and [#34](https://github.com/mattermost/mattermost-server/issues/).", report.Messages[0].Output)
+	assert.Equal(t, []string{"jira", "github"}, report.Messages[0].Links)
+	require.Len(t, report.Messages[0].Entities, 2)
+	assert.Equal(t, "MM-12", report.Messages[0].Entities[0].Text)
+	assert.Equal(t, "Read [docs/install](https://docs.mattermost.com/install.html) first.", report.Messages[1].Output)
+	assert.Equal(t, "Nothing to link here.", report.Messages[2].Output)
+	assert.Empty(t, report.Messages[2].Links)
+
+	// Link conditions are evaluated against the channel given on the command line.
+	stdout.Reset()
+	code = RunLint([]string{"-channel", "off-topic", "-messages", "testdata/lint/messages.txt", "testdata/lint/config.json"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "configuration migrated to version 2: pin-match-defaults, number-pattern-groups\n"+
+		`links[0] jira: warning: the named group "project" is not used by any template or validator`+"\n"+
+		"links[1] github: error: a template refers to $issue_id, which is not a group of the pattern\n"+
+		"links[3] broken: error: error parsing regexp: missing closing ): `(unclosed`\n"+
+		`message 1: "See [MM-12](https://mattermost.atlassian.net/browse/MM-12) and [#34](https://github.com/mattermost/mattermost-server/issues/)."`+"\n"+
+		"message 2: unchanged\n"+
+		"message 3: unchanged\n"+
+		"2 errors, 1 warnings\n", stdout.String())
+}
+
+func TestRunLintMessagesJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := RunLint([]string{"-json", "-strict", "-messages", "testdata/lint/messages.json", "testdata/lint/links.json"}, &stdout, &stderr)
+	assert.Equal(t, 0, code)
+
+	var report lintReport
+	require.Nil(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Empty(t, report.Issues)
+	require.Len(t, report.Messages, 1)
+	assert.Equal(t, "```\nMM-12\n```\n[MM-13](https://mattermost.atlassian.net/browse/MM-13)", report.Messages[0].Output)
+
+	code = RunLint([]string{"testdata/lint/missing.json"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	code = RunLint(nil, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "Usage: autolink-lint [flags] <config.json | links.json>")
+}
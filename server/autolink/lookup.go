@@ -0,0 +1,302 @@
+package autolink
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/plugin"
+)
+
+const (
+	lookupKeyPrefix = "lookup_"
+
+	defaultLookupTimeout  = 200 * time.Millisecond
+	defaultLookupCacheTTL = 10 * time.Minute
+
+	// lookupFailureTTL is how long a failed lookup is remembered, so that an unavailable
+	// service does not delay every post mentioning the same match.
+	lookupFailureTTL = 30 * time.Second
+
+	// maxLookupTimePerPost bounds the time spent waiting for lookups while processing a
+	// single post. Matches still to be looked up after that use the link's template.
+	maxLookupTimePerPost = time.Second
+
+	maxLookupCacheEntries   = 10000
+	maxLookupResponseLength = 1 << 20
+
+	// maxStoredLookups bounds the responses kept in the KV store. They are kept in as many
+	// slots, chosen by their URL, and a response replaces the one of another URL in its slot.
+	maxStoredLookups = maxLookupCacheEntries
+)
+
+var lookupFieldPattern = regexp.MustCompile(`\$\{lookup:([A-Za-z0-9_.\-]+)\}`)
+
+// markdownEscaper escapes the characters of a looked up value or a capture that could end
+// the label of a link or start other markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`,
+	"`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`, `<`, `\<`, `>`, `\>`,
+	"\n", " ", "\r", " ",
+)
+
+func checkLookup(lookup *Lookup) error {
+	if lookup.Template == "" {
+		return newLocalizedError("autolink.link.lookup_template_empty", nil)
+	}
+	u, err := url.Parse(lookup.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return newLocalizedError("autolink.link.lookup_url", nil)
+	}
+	return nil
+}
+
+func (l *Lookup) timeout() time.Duration {
+	if l.TimeoutMilliseconds > 0 {
+		return time.Duration(l.TimeoutMilliseconds) * time.Millisecond
+	}
+	return defaultLookupTimeout
+}
+
+func (l *Lookup) cacheTTL() time.Duration {
+	if l.CacheSeconds > 0 {
+		return time.Duration(l.CacheSeconds) * time.Second
+	}
+	return defaultLookupCacheTTL
+}
+
+// lookupEntry is a cached lookup response. Fields is nil for a failed lookup.
+type lookupEntry struct {
+	Fields  map[string]string
+	Expires int64
+
+	// URL is the URL of the response, as the slots of the KV store are shared.
+	URL string `json:",omitempty"`
+}
+
+func (e *lookupEntry) expired(now time.Time) bool {
+	return now.UnixNano() >= e.Expires
+}
+
+// lookupCache fetches lookup responses, caching them in memory and in the KV store so that
+// they are shared between the nodes of a cluster and survive restarts. The KV store holds at
+// most maxStoredLookups responses, and expired responses are removed from it when read.
+type lookupCache struct {
+	api    plugin.API
+	client *http.Client
+
+	lock    sync.Mutex
+	entries map[string]*lookupEntry
+}
+
+func newLookupCache(api plugin.API) *lookupCache {
+	return &lookupCache{
+		api:     api,
+		client:  &http.Client{},
+		entries: make(map[string]*lookupEntry),
+	}
+}
+
+func lookupKey(lookupURL string) string {
+	sum := md5.Sum([]byte(lookupURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupStoreKey returns the key of the slot of the KV store the response for the URL is
+// kept in.
+func lookupStoreKey(lookupURL string) string {
+	sum := md5.Sum([]byte(lookupURL))
+	return lookupKeyPrefix + strconv.FormatUint(binary.BigEndian.Uint64(sum[:8])%maxStoredLookups, 10)
+}
+
+// get returns the fields of the response for the URL, or nil if the lookup failed or did
+// not complete within the timeout.
+func (c *lookupCache) get(lookup *Lookup, lookupURL string, timeout time.Duration) map[string]string {
+	now := time.Now()
+	key := lookupKey(lookupURL)
+
+	c.lock.Lock()
+	entry := c.entries[key]
+	c.lock.Unlock()
+	if entry != nil && !entry.expired(now) {
+		return entry.Fields
+	}
+
+	storeKey := lookupStoreKey(lookupURL)
+	if data, appErr := c.api.KVGet(storeKey); appErr == nil && data != nil {
+		var stored lookupEntry
+		if err := json.Unmarshal(data, &stored); err == nil && stored.URL == lookupURL {
+			if !stored.expired(now) {
+				c.remember(key, &stored)
+				return stored.Fields
+			}
+			if appErr := c.api.KVDelete(storeKey); appErr != nil {
+				mlog.Warn("Failed to remove an expired lookup response: "+appErr.Error(), mlog.String("url", lookupURL))
+			}
+		}
+	}
+
+	fields, err := c.fetch(lookup, lookupURL, timeout)
+	if err != nil {
+		mlog.Warn("Lookup failed, using the plain template: "+err.Error(), mlog.String("url", lookupURL))
+		c.remember(key, &lookupEntry{Expires: now.Add(lookupFailureTTL).UnixNano()})
+		return nil
+	}
+
+	entry = &lookupEntry{Fields: fields, Expires: now.Add(lookup.cacheTTL()).UnixNano(), URL: lookupURL}
+	c.remember(key, entry)
+	if data, err := json.Marshal(entry); err == nil {
+		if appErr := c.api.KVSet(storeKey, data); appErr != nil {
+			mlog.Warn("Failed to cache lookup response: "+appErr.Error(), mlog.String("url", lookupURL))
+		}
+	}
+	return fields
+}
+
+// remember keeps an entry in memory, making room for it when the cache is full.
+func (c *lookupCache) remember(key string, entry *lookupEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.entries) >= maxLookupCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if e.expired(now) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < maxLookupCacheEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+func (c *lookupCache) fetch(lookup *Lookup, lookupURL string, timeout time.Duration) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Accept", "application/json")
+	for name, value := range lookup.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("lookup responded with status %d", resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxLookupResponseLength)).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	flattenLookupFields(fields, "", body)
+	return fields, nil
+}
+
+// flattenLookupFields collects the values of a JSON object, naming nested fields with dots.
+func flattenLookupFields(fields map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flattenLookupFields(fields, name, field)
+		}
+	case string:
+		fields[prefix] = v
+	case float64, bool:
+		fields[prefix] = fmt.Sprint(v)
+	}
+}
+
+// lookupReplacement builds the replacement of a match from the lookup template, or returns
+// false if a field used by the template is missing from the response.
+func lookupReplacement(lookup *Lookup, m *Match, fields map[string]string) (string, bool) {
+	ok := true
+	template := lookupFieldPattern.ReplaceAllStringFunc(lookup.Template, func(placeholder string) string {
+		value, found := fields[lookupFieldPattern.FindStringSubmatch(placeholder)[1]]
+		if !found {
+			ok = false
+		}
+		// Escape $ so that the value is not expanded as a group of the pattern.
+		return strings.Replace(markdownEscaper.Replace(value), "$", "$$", -1)
+	})
+	if !ok {
+		return "", false
+	}
+	return expandTemplate(splitTemplate(template), m.expand), true
+}
+
+// lookupURL expands the lookup URL with the groups of the match. The groups are escaped, in
+// the path and in the query, so that the text of a post cannot change which resource is
+// requested with the headers of the lookup.
+func lookupURL(lookup *Lookup, m *Match) string {
+	path, query := lookup.URL, ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+	return m.expand(path, escapePathSegment) + m.expand(query, url.QueryEscape)
+}
+
+// escapePathSegment escapes a value as a segment of a URL path, including the dots of . and
+// .., which would otherwise refer to other segments.
+func escapePathSegment(value string) string {
+	if value == "." || value == ".." {
+		return strings.Replace(value, ".", "%2E", -1)
+	}
+	return url.PathEscape(value)
+}
+
+// lookupMatch replaces the replacement of a match with the one built from the link's lookup,
+// leaving it unchanged if the lookup fails or the budget has no time left for it.
+func (p *Plugin) lookupMatch(l *AutoLinker, m *Match, budget *processingBudget) {
+	lookup := l.lookup
+	if lookup == nil || p.lookups == nil || m.expand == nil {
+		return
+	}
+
+	timeout := budget.lookupTimeout(lookup.timeout())
+	if timeout <= 0 {
+		return
+	}
+
+	started := time.Now()
+	fields := p.lookups.get(lookup, lookupURL(lookup, m), timeout)
+	budget.wait(time.Since(started))
+	if fields == nil {
+		return
+	}
+
+	if replacement, ok := lookupReplacement(lookup, m, fields); ok {
+		m.Replacement = replacement
+	}
+}
@@ -0,0 +1,207 @@
+package autolink
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLookupTest(t *testing.T, lookup *Lookup) (*Plugin, map[string][]byte) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "jira",
+			Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+			Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+			Lookup:   lookup,
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	store := mockKVStore(api)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+	p.lookups = newLookupCache(api)
+	return p, store
+}
+
+func newLookupServer(requests *int32, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		time.Sleep(delay)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key": "MM-" + r.URL.Query().Get("id"),
+			"fields": map[string]interface{}{
+				"summary": "Fix login [crash]",
+				"status":  map[string]interface{}{"name": "Closed"},
+				"votes":   3,
+			},
+		})
+	}))
+}
+
+func jiraLookup(serverURL string) *Lookup {
+	return &Lookup{
+		URL:      serverURL + "/issue?id=${jira_id}",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Template: "[MM-${jira_id}: ${lookup:fields.summary} (${lookup:fields.status.name})](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var requests int32
+	server := newLookupServer(&requests, 0)
+	defer server.Close()
+
+	p, store := setupLookupTest(t, jiraLookup(server.URL))
+
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "See MM-12345"})
+	assert.Equal(t, "See [MM-12345: Fix login \\[crash\\] (Closed)](https://mattermost.atlassian.net/browse/MM-12345)", post.Message)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Contains(t, store, lookupStoreKey(server.URL+"/issue?id=12345"))
+
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "Again MM-12345"})
+	assert.Equal(t, "Again [MM-12345: Fix login \\[crash\\] (Closed)](https://mattermost.atlassian.net/browse/MM-12345)", post.Message)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "the response should be cached in memory")
+
+	// A new node, sharing the KV store, uses the cached response.
+	p.lookups = newLookupCache(p.API)
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-12345"})
+	assert.Equal(t, "[MM-12345: Fix login \\[crash\\] (Closed)](https://mattermost.atlassian.net/browse/MM-12345)", post.Message)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "the response should be cached in the KV store")
+}
+
+func TestLookupStore(t *testing.T) {
+	var requests int32
+	server := newLookupServer(&requests, 0)
+	defer server.Close()
+
+	lookup := jiraLookup(server.URL)
+	lookup.Template = "[MM-${jira_id}: ${lookup:fields.summary}](https://mattermost.atlassian.net/browse/MM-${jira_id})"
+	p, store := setupLookupTest(t, lookup)
+	lookupURL := server.URL + "/issue?id=1"
+	key := lookupStoreKey(lookupURL)
+
+	// A response for another URL sharing the slot is not used, and is replaced.
+	other, err := json.Marshal(&lookupEntry{Fields: map[string]string{"fields.summary": "Other"}, Expires: time.Now().Add(time.Hour).UnixNano(), URL: server.URL + "/other"})
+	require.Nil(t, err)
+	store[key] = other
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-1"})
+	assert.Equal(t, "[MM-1: Fix login \\[crash\\]](https://mattermost.atlassian.net/browse/MM-1)", post.Message)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	var stored lookupEntry
+	require.Nil(t, json.Unmarshal(store[key], &stored))
+	assert.Equal(t, lookupURL, stored.URL)
+
+	// Expired responses are removed from the KV store when read.
+	stored.Expires = time.Now().Add(-time.Second).UnixNano()
+	expired, err := json.Marshal(&stored)
+	require.Nil(t, err)
+	store[key] = expired
+	server.Close()
+	p.lookups = newLookupCache(p.API)
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-1"})
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", post.Message)
+	assert.NotContains(t, store, key)
+}
+
+func TestLookupFallback(t *testing.T) {
+	var requests int32
+	server := newLookupServer(&requests, 0)
+	defer server.Close()
+
+	lookup := jiraLookup(server.URL)
+	lookup.Template = "[MM-${jira_id}: ${lookup:fields.missing}](https://mattermost.atlassian.net/browse/MM-${jira_id})"
+	p, _ := setupLookupTest(t, lookup)
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-1"})
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1)", post.Message)
+
+	lookup = jiraLookup(server.URL)
+	lookup.Headers = nil
+	p, _ = setupLookupTest(t, lookup)
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-2"})
+	assert.Equal(t, "[MM-2](https://mattermost.atlassian.net/browse/MM-2)", post.Message)
+
+	// Failures are remembered for a while.
+	atomic.StoreInt32(&requests, 0)
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-2"})
+	assert.Equal(t, "[MM-2](https://mattermost.atlassian.net/browse/MM-2)", post.Message)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestLookupTimeout(t *testing.T) {
+	var requests int32
+	server := newLookupServer(&requests, 500*time.Millisecond)
+	defer server.Close()
+
+	lookup := jiraLookup(server.URL)
+	lookup.TimeoutMilliseconds = 50
+	p, _ := setupLookupTest(t, lookup)
+
+	start := time.Now()
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "MM-1 MM-2 MM-3"})
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1) [MM-2](https://mattermost.atlassian.net/browse/MM-2) [MM-3](https://mattermost.atlassian.net/browse/MM-3)", post.Message)
+	assert.True(t, time.Since(start) < 400*time.Millisecond)
+}
+
+func TestLookupURL(t *testing.T) {
+	lookup := &Lookup{URL: "https://example.com/items/${key}/details?q=${key}&v=2", Template: "${key}"}
+	for name, tc := range map[string]struct {
+		link     *Link
+		text     string
+		expected string
+	}{
+		"regexp": {
+			&Link{Pattern: "key:(?P<key>\\S+)", Template: "${key}", Lookup: lookup},
+			"key:../admin?x=1&y=2#z",
+			"https://example.com/items/..%2Fadmin%3Fx=1&y=2%23z/details?q=..%2Fadmin%3Fx%3D1%26y%3D2%23z&v=2",
+		},
+		"dot segment": {
+			&Link{Pattern: "key:(?P<key>\\S+)", Template: "${key}", Lookup: lookup},
+			"key:..",
+			"https://example.com/items/%2E%2E/details?q=..&v=2",
+		},
+		"simple": {
+			&Link{Type: linkTypeSimple, Pattern: "key:{key:text}", Template: "${key}", Lookup: lookup},
+			"key:a/b?c",
+			"https://example.com/items/a%2Fb%3Fc/details?q=a%2Fb%3Fc&v=2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewAutoLinker(tc.link, nil)
+			require.Nil(t, err)
+			matches := l.Matches(tc.text)
+			require.Len(t, matches, 1)
+			assert.Equal(t, tc.expected, lookupURL(l.lookup, matches[0]))
+		})
+	}
+}
+
+func TestLookupValidation(t *testing.T) {
+	link := &Link{Pattern: "(MM)", Template: "MM", Lookup: &Lookup{URL: "/relative", Template: "MM"}}
+	_, err := NewAutoLinker(link, nil)
+	assert.EqualError(t, err, "Lookup URL must be an absolute http or https URL")
+
+	link.Lookup = &Lookup{URL: "https://example.com"}
+	_, err = NewAutoLinker(link, nil)
+	assert.EqualError(t, err, "Lookup template was empty")
+}
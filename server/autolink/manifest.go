@@ -1,4 +1,4 @@
-package main
+package autolink
 
 var manifest = struct {
 	Id      string
@@ -0,0 +1,436 @@
+package autolink
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Link types, selecting the Matcher of a link.
+const (
+	linkTypeRegexp     = "regexp"
+	linkTypeSimple     = "simple"
+	linkTypeDictionary = "dictionary"
+)
+
+// Matcher finds the text a link applies to in a message. Every link type has its own
+// Matcher, while templates, validators, lookups and conditions are shared by all types.
+type Matcher interface {
+	// Groups returns the names of the groups of the matches, which templates and validators
+	// can refer to.
+	Groups() []string
+
+	// FindAll returns the matches in the message, in order and without overlap.
+	FindAll(message string) []*Submatch
+}
+
+// Submatch is a match found by a Matcher.
+type Submatch struct {
+	// Start and End are the byte offsets of the text to replace.
+	Start, End int
+
+	// Groups holds the groups that took part in the match, in the order of Matcher.Groups.
+	Groups []Group
+
+	// Expand expands a template with the match, passing the value of every group through
+	// escape if it is set. If Expand is nil, the template is expanded with the groups of the
+	// match.
+	Expand func(template string, escape func(string) string) string
+}
+
+// Group is a named part of a match.
+type Group struct {
+	Name  string
+	Value string
+}
+
+// MatcherFactory creates the Matcher of a link. The configuration supplies the limits the
+// link is checked against and may be nil to use the defaults.
+type MatcherFactory func(link *Link, conf *Configuration) (Matcher, error)
+
+var matcherFactories = map[string]MatcherFactory{
+	linkTypeRegexp:     newRegexpMatcher,
+	linkTypeSimple:     newSimpleMatcher,
+	linkTypeDictionary: newDictionaryMatcher,
+}
+
+// RegisterMatcher makes a link type available to the configuration. It is meant to be called
+// from init functions, and panics if the type is already registered.
+func RegisterMatcher(linkType string, factory MatcherFactory) {
+	if _, ok := matcherFactories[linkType]; ok {
+		panic(fmt.Sprintf("link type %q is already registered", linkType))
+	}
+	matcherFactories[linkType] = factory
+}
+
+// newMatcher creates the Matcher for the type of the link. Links without a type use regular
+// expressions.
+func newMatcher(link *Link, conf *Configuration) (Matcher, error) {
+	linkType := link.Type
+	if linkType == "" {
+		linkType = linkTypeRegexp
+	}
+
+	factory, ok := matcherFactories[linkType]
+	if !ok {
+		return nil, newLocalizedError("autolink.link.unknown_type", map[string]interface{}{"Type": link.Type})
+	}
+	return factory(link, conf)
+}
+
+// expandGroups expands $name and ${name} in the template with the groups of a match, like
+// regexp.Expand does with the groups of a pattern, passing their values through escape if it
+// is set. Unknown groups expand to nothing and $$ inserts a literal $.
+func expandGroups(template string, groups []Group, escape func(string) string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		b.WriteString(template[:i])
+		template = template[i+1:]
+
+		if strings.HasPrefix(template, "$") {
+			b.WriteByte('$')
+			template = template[1:]
+			continue
+		}
+
+		name, rest, ok := extractGroupName(template)
+		if !ok {
+			// Malformed, keep the $ as is.
+			b.WriteByte('$')
+			continue
+		}
+		for _, g := range groups {
+			if g.Name == name {
+				if escape != nil {
+					b.WriteString(escape(g.Value))
+				} else {
+					b.WriteString(g.Value)
+				}
+				break
+			}
+		}
+		template = rest
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// extractGroupName returns the name at the start of s, following a $, and the rest of s.
+func extractGroupName(s string) (string, string, bool) {
+	brace := strings.HasPrefix(s, "{")
+	if brace {
+		s = s[1:]
+	}
+
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", "", false
+	}
+
+	name, rest := s[:i], s[i:]
+	if brace {
+		if !strings.HasPrefix(rest, "}") {
+			return "", "", false
+		}
+		rest = rest[1:]
+	}
+	return name, rest, true
+}
+
+// renumberGroups replaces the numbered groups of the template, $n and ${n}, with what number
+// returns for n. Named groups and $$ are kept as they are.
+func renumberGroups(template string, number func(n int) string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		b.WriteString(template[:i])
+		template = template[i+1:]
+
+		if strings.HasPrefix(template, "$") {
+			b.WriteString("$$")
+			template = template[1:]
+			continue
+		}
+
+		name, rest, ok := extractGroupName(template)
+		if n, err := strconv.Atoi(name); ok && err == nil {
+			b.WriteString(number(n))
+			template = rest
+			continue
+		}
+		b.WriteByte('$')
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// regexpMatcher matches a regular expression, optionally requiring a whitespace or the start
+// of the message before a match and a whitespace, punctuation or the end of the message after.
+type regexpMatcher struct {
+	// expression is the regular expression of the link, without the prefix and suffix.
+	expression string
+
+	pattern *regexp.Regexp
+	groups  []string
+
+	// continuation is used to look for a match right after a previous one, whose suffix
+	// is then reused as the prefix. It is only set when both prefix and suffix are enabled.
+	continuation *regexp.Regexp
+
+	// unicodeBoundaries checks the boundaries of the matches of the pattern in Go rather
+	// than with prefix and suffix groups.
+	unicodeBoundaries        bool
+	checkPrefix, checkSuffix bool
+
+	// boundaryGroups is set if the pattern has prefix or suffix groups, and groupOffset is
+	// the number of groups before the groups of the link's own pattern, of which there are
+	// patternGroups. Templates number the groups of the link's own pattern only.
+	boundaryGroups bool
+	groupOffset    int
+	patternGroups  int
+}
+
+func newRegexpMatcher(link *Link, conf *Configuration) (Matcher, error) {
+	if len(link.Pattern) == 0 {
+		return nil, errPatternOrTemplateEmpty
+	}
+
+	if err := checkBoundaries(link); err != nil {
+		return nil, err
+	}
+	if link.Boundaries == boundariesUnicode {
+		return newUnicodeRegexpMatcher(link, conf)
+	}
+
+	pattern := link.Pattern
+	continuation := ""
+
+	if !link.DisableNonWordPrefix {
+		continuation = "(?P<" + prefixGroup + ">\\s)" + pattern
+		pattern = "(?P<" + prefixGroup + ">^|\\s)" + pattern
+	}
+
+	if !link.DisableNonWordSuffix {
+		pattern = pattern + suffixPattern
+		continuation = continuation + suffixPattern
+	}
+
+	if err := checkPatternLimits(link, pattern, conf); err != nil {
+		return nil, err
+	}
+
+	p, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &regexpMatcher{
+		expression:     link.Pattern,
+		pattern:        p,
+		boundaryGroups: !link.DisableNonWordPrefix || !link.DisableNonWordSuffix,
+	}
+	if !link.DisableNonWordPrefix {
+		m.groupOffset = 1
+	}
+	if own, err := regexp.Compile(link.Pattern); err == nil {
+		m.patternGroups = own.NumSubexp()
+	}
+	for _, name := range p.SubexpNames() {
+		if name != "" && name != prefixGroup && name != suffixGroup {
+			m.groups = append(m.groups, name)
+		}
+	}
+
+	if !link.DisableNonWordPrefix && !link.DisableNonWordSuffix {
+		if m.continuation, err = regexp.Compile(continuation); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// newUnicodeRegexpMatcher matches the pattern of the link as is, leaving the boundaries to
+// be checked by FindAll.
+func newUnicodeRegexpMatcher(link *Link, conf *Configuration) (Matcher, error) {
+	if err := checkPatternLimits(link, link.Pattern, conf); err != nil {
+		return nil, err
+	}
+
+	p, err := regexp.Compile(link.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &regexpMatcher{
+		expression:        link.Pattern,
+		pattern:           p,
+		unicodeBoundaries: true,
+		checkPrefix:       !link.DisableNonWordPrefix,
+		checkSuffix:       !link.DisableNonWordSuffix,
+	}
+	for _, name := range p.SubexpNames() {
+		if name != "" {
+			m.groups = append(m.groups, name)
+		}
+	}
+	return m, nil
+}
+
+func (m *regexpMatcher) Groups() []string {
+	return m.groups
+}
+
+func (m *regexpMatcher) FindAll(message string) []*Submatch {
+	if m.unicodeBoundaries {
+		return m.findAllAtUnicodeBoundaries(message)
+	}
+
+	if m.continuation == nil {
+		var matches []*Submatch
+		for _, loc := range m.pattern.FindAllStringSubmatchIndex(message, -1) {
+			matches = append(matches, m.newSubmatch(m.pattern, message, 0, loc))
+		}
+		return matches
+	}
+
+	// The prefix and suffix both consume a character, so two matches back to back share
+	// the character between them. Look for the next match starting at the suffix of the
+	// previous one, requiring that suffix to be whitespace.
+	var matches []*Submatch
+	pattern := m.pattern
+	for pos := 0; pos <= len(message); {
+		loc := pattern.FindStringSubmatchIndex(message[pos:])
+		if loc == nil {
+			break
+		}
+
+		sm := m.newSubmatch(pattern, message[pos:], pos, loc)
+		matches = append(matches, sm)
+
+		next := sm.End
+		if next <= pos {
+			_, size := utf8.DecodeRuneInString(message[pos:])
+			next = pos + size
+		}
+		pos = next
+		pattern = m.continuation
+	}
+	return matches
+}
+
+// findAllAtUnicodeBoundaries returns the matches of the pattern that start and end at
+// boundaries. When a match is not at boundaries, the search resumes right after its start,
+// since a match starting later may be. Shorter matches at the same start are not tried: the
+// pattern decides where a match ends, and the text after it would no longer be seen by $ or
+// \b in the pattern.
+func (m *regexpMatcher) findAllAtUnicodeBoundaries(message string) []*Submatch {
+	var matches []*Submatch
+	for pos := 0; pos <= len(message); {
+		loc := m.pattern.FindStringSubmatchIndex(message[pos:])
+		if loc == nil {
+			break
+		}
+
+		start, end := pos+loc[0], pos+loc[1]
+		if atUnicodeBoundaries(message, start, end, m.checkPrefix, m.checkSuffix) {
+			matches = append(matches, m.newSubmatch(m.pattern, message[pos:], pos, loc))
+			pos = end
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(message[start:])
+		if size == 0 {
+			break
+		}
+		pos = start + size
+	}
+	return matches
+}
+
+// newSubmatch builds the match found at loc in text, which starts at offset in the message.
+// The prefix and suffix groups are left out of the range of the match, and of $0.
+func (m *regexpMatcher) newSubmatch(pattern *regexp.Regexp, text string, offset int, loc []int) *Submatch {
+	start, end := loc[0], loc[1]
+	var groups []Group
+	for i, name := range pattern.SubexpNames() {
+		if name == "" || loc[2*i] < 0 {
+			continue
+		}
+
+		switch name {
+		case prefixGroup:
+			start = loc[2*i+1]
+		case suffixGroup:
+			end = loc[2*i]
+		default:
+			groups = append(groups, Group{Name: name, Value: text[loc[2*i]:loc[2*i+1]]})
+		}
+	}
+
+	expandLoc := append([]int{start, end}, loc[2:]...)
+	return &Submatch{
+		Start:  offset + start,
+		End:    offset + end,
+		Groups: groups,
+		Expand: func(template string, escape func(string) string) string {
+			template = m.numberGroups(template)
+			if escape == nil {
+				return string(pattern.ExpandString(nil, template, text, expandLoc))
+			}
+			escaped, escapedLoc := escapeSubmatches(text, expandLoc, escape)
+			return string(pattern.ExpandString(nil, template, escaped, escapedLoc))
+		},
+	}
+}
+
+// numberGroups turns the numbered groups of a template, which count the groups of the link's
+// own pattern, into groups of the pattern with the prefix and suffix groups. Numbers past the
+// groups of the link's pattern expand to nothing.
+func (m *regexpMatcher) numberGroups(template string) string {
+	if !m.boundaryGroups {
+		return template
+	}
+	return renumberGroups(template, func(n int) string {
+		if n == 0 {
+			return "${0}"
+		}
+		if n > m.patternGroups {
+			return ""
+		}
+		return "${" + strconv.Itoa(n+m.groupOffset) + "}"
+	})
+}
+
+// escapeSubmatches returns the escaped value of every group found at loc in text, one after
+// the other, along with their positions in the result.
+func escapeSubmatches(text string, loc []int, escape func(string) string) (string, []int) {
+	var b strings.Builder
+	escapedLoc := make([]int, len(loc))
+	for i := 0; i < len(loc); i += 2 {
+		if loc[i] < 0 {
+			escapedLoc[i], escapedLoc[i+1] = -1, -1
+			continue
+		}
+		escapedLoc[i] = b.Len()
+		b.WriteString(escape(text[loc[i]:loc[i+1]]))
+		escapedLoc[i+1] = b.Len()
+	}
+	return b.String(), escapedLoc
+}
@@ -0,0 +1,78 @@
+package autolink
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperMatcher matches words written in capitals, standing in for a matcher registered by
+// another part of the plugin.
+type upperMatcher struct{}
+
+func (upperMatcher) Groups() []string {
+	return []string{"word", "lower"}
+}
+
+func (upperMatcher) FindAll(message string) []*Submatch {
+	var matches []*Submatch
+	pos := 0
+	for _, word := range strings.Fields(message) {
+		start := pos + strings.Index(message[pos:], word)
+		pos = start + len(word)
+		if len(word) > 1 && strings.ToUpper(word) == word {
+			matches = append(matches, &Submatch{
+				Start:  start,
+				End:    pos,
+				Groups: []Group{{Name: "word", Value: word}, {Name: "lower", Value: strings.ToLower(word)}},
+			})
+		}
+	}
+	return matches
+}
+
+func TestRegisterMatcher(t *testing.T) {
+	RegisterMatcher("test-upper", func(link *Link, conf *Configuration) (Matcher, error) {
+		return upperMatcher{}, nil
+	})
+	defer delete(matcherFactories, "test-upper")
+
+	assert.Panics(t, func() {
+		RegisterMatcher("test-upper", func(link *Link, conf *Configuration) (Matcher, error) {
+			return upperMatcher{}, nil
+		})
+	})
+
+	al, err := NewAutoLinker(&Link{
+		Name:       "acronyms",
+		Type:       "test-upper",
+		Template:   "[${word}](https://example.com/glossary/${lower})",
+		Validators: map[string]*Validator{"word": {MaxLength: 4}},
+	}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "The [LHS](https://example.com/glossary/lhs) and RHSAAAA", al.Replace("The LHS and RHSAAAA"))
+
+	_, err = NewAutoLinker(&Link{Type: "unknown", Template: "x"}, nil)
+	assert.EqualError(t, err, `unknown link type "unknown"`)
+}
+
+func TestExpandGroups(t *testing.T) {
+	groups := []Group{{Name: "id", Value: "123"}, {Name: "id2", Value: "456"}}
+	for template, expected := range map[string]string{
+		"MM-$id":       "MM-123",
+		"MM-${id}x":    "MM-123x",
+		"MM-$idx":      "MM-",
+		"MM-$id2":      "MM-456",
+		"$$id costs $": "$id costs $",
+		"${id":         "${id",
+		"no groups":    "no groups",
+	} {
+		assert.Equal(t, expected, expandGroups(template, groups, nil), template)
+	}
+
+	escaped := expandGroups("/${id}/$$id", []Group{{Name: "id", Value: "a/b"}}, url.PathEscape)
+	assert.Equal(t, "/a%2Fb/$id", escaped)
+}
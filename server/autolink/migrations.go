@@ -0,0 +1,129 @@
+package autolink
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// configurationMigration upgrades the configuration from one version of its schema to the next.
+type configurationMigration struct {
+	// Name is recorded in the Migrations of the configuration once the migration ran.
+	Name    string
+	migrate func(c *Configuration) error
+}
+
+// configurationMigrations upgrade the configuration one version at a time: the migration at
+// index i upgrades version i to version i+1. Migrations are only ever appended, so that the
+// version of a configuration keeps its meaning.
+//
+// A migration is needed whenever a change would alter what an existing configuration means,
+// such as a new default for an existing setting. The migration makes the old behavior
+// explicit in older configurations before the new default applies.
+var configurationMigrations = []configurationMigration{
+	{Name: "pin-match-defaults", migrate: pinMatchDefaults},
+	{Name: "number-pattern-groups", migrate: numberPatternGroups},
+}
+
+// currentConfigurationVersion is the version of the configuration schema of this plugin.
+func currentConfigurationVersion() int {
+	return len(configurationMigrations)
+}
+
+// migrateConfiguration upgrades the configuration to the current version and returns the
+// names of the migrations that ran. Configurations written by a newer version of the plugin
+// are rejected, since their settings may mean something this version does not know about.
+func migrateConfiguration(c *Configuration) ([]string, error) {
+	current := currentConfigurationVersion()
+	if c.Version < 0 || c.Version > current {
+		return nil, newLocalizedError("autolink.configuration.unsupported_version", map[string]interface{}{"Version": c.Version, "Current": current})
+	}
+
+	var ran []string
+	for c.Version < current {
+		m := configurationMigrations[c.Version]
+		if err := m.migrate(c); err != nil {
+			return ran, newLocalizedError("autolink.configuration.migration_failed", map[string]interface{}{"Name": m.Name, "Error": err})
+		}
+		c.Version++
+		c.Migrations = append(c.Migrations, m.Name)
+		ran = append(ran, m.Name)
+	}
+	return ran, nil
+}
+
+// pinMatchDefaults upgrades configurations written before the schema was versioned. It sets
+// the Type and Boundaries of every link to the defaults these configurations were written
+// for, so that changing the defaults later leaves their links as they are.
+func pinMatchDefaults(c *Configuration) error {
+	for _, l := range c.Links {
+		if l == nil {
+			continue
+		}
+		if l.Type == "" {
+			l.Type = linkTypeRegexp
+		}
+		if l.Boundaries == "" {
+			l.Boundaries = boundariesWhitespace
+		}
+	}
+	return nil
+}
+
+// numberPatternGroups upgrades configurations in which the numbered groups of regexp links
+// with whitespace boundaries counted the prefix group added before the pattern, unless
+// DisableNonWordPrefix was set, and the suffix group added after it, and in which $0 included
+// the prefix and suffix. Templates now number the groups of the link's own pattern only, so
+// the prefix and suffix are referred to by name instead, and the other groups renumbered,
+// for the templates to expand as they did.
+func numberPatternGroups(c *Configuration) error {
+	for _, l := range c.Links {
+		if l == nil || (l.Type != "" && l.Type != linkTypeRegexp) || (l.Boundaries != "" && l.Boundaries != boundariesWhitespace) {
+			continue
+		}
+		if l.DisableNonWordPrefix && l.DisableNonWordSuffix {
+			continue
+		}
+		pattern, err := regexp.Compile(l.Pattern)
+		if err != nil {
+			// The link is reported when it is compiled.
+			continue
+		}
+
+		offset := 0
+		if !l.DisableNonWordPrefix {
+			offset = 1
+		}
+		suffix := offset + pattern.NumSubexp() + 1
+		number := func(n int) string {
+			switch {
+			case n == 0:
+				whole := "${0}"
+				if !l.DisableNonWordPrefix {
+					whole = "${" + prefixGroup + "}" + whole
+				}
+				if !l.DisableNonWordSuffix {
+					whole += "${" + suffixGroup + "}"
+				}
+				return whole
+			case n <= offset:
+				return "${" + prefixGroup + "}"
+			case n < suffix:
+				return "${" + strconv.Itoa(n-offset) + "}"
+			case n == suffix && !l.DisableNonWordSuffix:
+				return "${" + suffixGroup + "}"
+			}
+			// Groups past the last one always expanded to nothing.
+			return ""
+		}
+
+		l.Template = renumberGroups(l.Template, number)
+		for name, template := range l.Templates {
+			l.Templates[name] = renumberGroups(template, number)
+		}
+		if l.Lookup != nil {
+			l.Lookup.URL = renumberGroups(l.Lookup.URL, number)
+			l.Lookup.Template = renumberGroups(l.Lookup.Template, number)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package autolink
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrationFixtures migrates every testdata/migrations/<name>.in.json and compares the
+// result with <name>.out.json.
+func TestMigrationFixtures(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "migrations", "*.in.json"))
+	require.Nil(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".in.json")
+		t.Run(name, func(t *testing.T) {
+			var c Configuration
+			readFixture(t, input, &c)
+			var expected Configuration
+			readFixture(t, strings.TrimSuffix(input, ".in.json")+".out.json", &expected)
+
+			_, err := migrateConfiguration(&c)
+			require.Nil(t, err)
+			assert.Equal(t, expected, c)
+
+			// Links of migrated configurations are valid in the current version.
+			for _, l := range c.Links {
+				_, err := NewAutoLinker(l, &c)
+				assert.Nil(t, err, l.DisplayName())
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, path string, v interface{}) {
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(data, v), path)
+}
+
+func TestMigrationChain(t *testing.T) {
+	original := configurationMigrations
+	defer func() { configurationMigrations = original }()
+
+	var order []string
+	migration := func(name string) configurationMigration {
+		return configurationMigration{Name: name, migrate: func(c *Configuration) error {
+			order = append(order, name)
+			return nil
+		}}
+	}
+	configurationMigrations = []configurationMigration{migration("first"), migration("second"), migration("third")}
+
+	c := &Configuration{Version: 1, Migrations: []string{"first"}}
+	ran, err := migrateConfiguration(c)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"second", "third"}, ran)
+	assert.Equal(t, []string{"second", "third"}, order)
+	assert.Equal(t, 3, c.Version)
+	assert.Equal(t, []string{"first", "second", "third"}, c.Migrations)
+
+	ran, err = migrateConfiguration(c)
+	require.Nil(t, err)
+	assert.Empty(t, ran)
+
+	_, err = migrateConfiguration(&Configuration{Version: 4})
+	assert.EqualError(t, err, "configuration version 4 is not supported, the latest supported version is 3")
+	de := i18n.TranslateFunc(translations.MustTfunc("de"))
+	assert.Equal(t, "Konfigurationsversion 4 wird nicht unterstützt, die neueste unterstützte Version ist 3", translateError(de, err))
+
+	configurationMigrations[1].migrate = func(c *Configuration) error {
+		return errors.New("bad link")
+	}
+	c = &Configuration{}
+	ran, err = migrateConfiguration(c)
+	assert.EqualError(t, err, "configuration migration second failed: bad link")
+	assert.Equal(t, []string{"first"}, ran)
+	assert.Equal(t, 1, c.Version)
+}
+
+// TestNumberPatternGroups checks that migrated templates expand as they did when numbered
+// groups counted the prefix and suffix groups.
+func TestNumberPatternGroups(t *testing.T) {
+	var c Configuration
+	readFixture(t, filepath.Join("testdata", "migrations", "v1-boundary-groups.in.json"), &c)
+	_, err := migrateConfiguration(&c)
+	require.Nil(t, err)
+
+	for name, tc := range map[string]struct {
+		message  string
+		expected string
+	}{
+		"jira":    {"See MM-12.", "See [MM-12](https://mattermost.atlassian.net/browse/MM-12)."},
+		"ticket":  {"Closes #34 today", "Closes [#34 ](https://tickets.example.com/34) today"},
+		"release": {"Released v5.1", "Released [v5](https://example.com/releases/5)"},
+		"commit":  {"Reverts 0a1b2c3", "Reverts [0a1b2c3](https://github.com/mattermost/mattermost-server/commit/0a1b2c3) costs $1"},
+		"unicode": {"Merged PR-7", "Merged [PR-7](https://example.com/pulls/7)"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, l := range c.Links {
+				if l.Name != name {
+					continue
+				}
+				al, err := NewAutoLinker(l, &c)
+				require.Nil(t, err)
+				assert.Equal(t, tc.expected, al.Replace(tc.message))
+			}
+		})
+	}
+
+	// $0 included the prefix as well, and groups past the last one expanded to nothing.
+	release := *c.Links[2]
+	release.Templates = nil
+	al, err := NewAutoLinker(&release, &c)
+	require.Nil(t, err)
+	assert.Equal(t, "Released  [ v5.1](https://example.com/releases/5/1)", al.Replace("Released v5.1"))
+}
+
+func TestMigrationSaved(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = Configuration{Links: []*Link{{
+			Name:     "jira",
+			Pattern:  "MM-(?P<jira_id>\\d+)",
+			Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+		}}}
+		return nil
+	})
+	api.On("GetConfig").Return(&model.Config{})
+
+	var saved map[string]interface{}
+	api.On("SaveConfig", mock.AnythingOfType("*model.Config")).Return(func(config *model.Config) *model.AppError {
+		saved = config.PluginSettings.Plugins[manifest.Id]
+		return nil
+	})
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	conf := p.getConfiguration()
+	assert.Equal(t, currentConfigurationVersion(), conf.Version)
+	assert.Equal(t, linkTypeRegexp, conf.Links[0].Type)
+	assert.Equal(t, boundariesWhitespace, conf.Links[0].Boundaries)
+
+	require.Nil(t, p.saveLinks(conf.Links, &linkRevision{Change: changeUpdate}))
+	assert.Equal(t, currentConfigurationVersion(), saved["version"])
+	assert.Equal(t, []string{"pin-match-defaults", "number-pattern-groups"}, saved["migrations"])
+}
@@ -0,0 +1,278 @@
+package autolink
+
+import (
+	"regexp/syntax"
+	"sort"
+	"unicode"
+
+	"github.com/mattermost/mattermost-server/mlog"
+)
+
+// Kinds of interference between two links.
+const (
+	// overlapShadowed is a link that never changes its samples, because an earlier link
+	// always changes them first.
+	overlapShadowed = "shadowed"
+
+	// overlapConflict is two links matching the same text and linking it to different URLs.
+	overlapConflict = "conflict"
+
+	// overlapMatch is two links matching the same text.
+	overlapMatch = "overlap"
+)
+
+const (
+	// maxOverlapSamples bounds the samples generated from a pattern, since alternatives and
+	// optional parts multiply them.
+	maxOverlapSamples = 8
+
+	// maxSampleRepeat bounds the repetitions of a part of a pattern in a sample.
+	maxSampleRepeat = 64
+)
+
+// linkOverlap is a way two links interfere with each other, found by applying both links to
+// sample texts generated from their patterns. Other is the earlier link.
+type linkOverlap struct {
+	Kind        string
+	Link, Other *AutoLinker
+
+	// Text is the sample both links were applied to, and URL and OtherURL where they link
+	// it for a conflict.
+	Text          string
+	URL, OtherURL string
+}
+
+// warning describes the overlap.
+func (o *linkOverlap) warning() error {
+	params := map[string]interface{}{
+		"Link":     o.Link.link.DisplayName(),
+		"Other":    o.Other.link.DisplayName(),
+		"Text":     o.Text,
+		"URL":      o.URL,
+		"OtherURL": o.OtherURL,
+	}
+	return newLocalizedError("autolink.overlap."+o.Kind, params)
+}
+
+// analyzeOverlaps finds the links that interfere with an earlier one, in order: links that
+// never apply because an earlier link always wins, links matching the same text as an
+// earlier link with a different URL, and links matching the same text as an earlier link.
+// Every pair of links is reported once, with its most serious kind.
+//
+// Links with conditions only interfere with links without conditions, since links that both
+// have conditions may never apply to the same posts.
+func analyzeOverlaps(links []*AutoLinker) []*linkOverlap {
+	samples := make([][]string, len(links))
+	for i, l := range links {
+		samples[i] = linkSamples(l)
+	}
+
+	var overlaps []*linkOverlap
+	for i, l := range links {
+		if len(samples[i]) == 0 {
+			continue
+		}
+
+		winner, text := shadowingLink(links[:i], l, samples[i])
+		if winner != nil {
+			overlaps = append(overlaps, &linkOverlap{Kind: overlapShadowed, Link: l, Other: winner, Text: text})
+		}
+
+		for j, other := range links[:i] {
+			if other == winner || (l.conditions != nil && other.conditions != nil) {
+				continue
+			}
+			pairSamples := append(append([]string(nil), samples[j]...), samples[i]...)
+			if o := matchOverlap(l, other, pairSamples); o != nil {
+				overlaps = append(overlaps, o)
+			}
+		}
+	}
+	return overlaps
+}
+
+// shadowingLink returns the earlier link that changes the samples of the link before it
+// applies, if the link does not apply to any of its samples after the earlier links, and the
+// first sample.
+func shadowingLink(earlier []*AutoLinker, l *AutoLinker, samples []string) (*AutoLinker, string) {
+	var winner *AutoLinker
+	for _, sample := range samples {
+		text, first := sample, (*AutoLinker)(nil)
+		for _, e := range earlier {
+			if e.conditions != nil {
+				continue
+			}
+			if matches := e.Matches(text); len(matches) > 0 {
+				text = applyMatches(text, matches)
+				if first == nil {
+					first = e
+				}
+			}
+		}
+		if first == nil || len(l.Matches(text)) > 0 {
+			return nil, ""
+		}
+		if winner == nil {
+			winner = first
+		}
+	}
+	return winner, samples[0]
+}
+
+// matchOverlap returns how the links overlap on the first sample both match, or nil if they
+// match none of the samples together. Matches overlap if they share any text, and conflict
+// if they match exactly the same text and link it to different URLs.
+func matchOverlap(l, other *AutoLinker, samples []string) *linkOverlap {
+	var found *linkOverlap
+	for _, sample := range samples {
+		for _, m := range l.Matches(sample) {
+			for _, om := range other.Matches(sample) {
+				if m.End <= om.Start || om.End <= m.Start {
+					continue
+				}
+				url, otherURL := m.URL(), om.URL()
+				if m.Start == om.Start && m.End == om.End && url != otherURL {
+					return &linkOverlap{Kind: overlapConflict, Link: l, Other: other, Text: m.Text, URL: url, OtherURL: otherURL}
+				}
+				if found == nil {
+					found = &linkOverlap{Kind: overlapMatch, Link: l, Other: other, Text: m.Text}
+				}
+			}
+		}
+	}
+	return found
+}
+
+// linkSamples returns texts the link matches as a whole, generated from its pattern or
+// dictionary. Links of other types have no samples.
+func linkSamples(l *AutoLinker) []string {
+	var candidates []string
+	switch m := l.matcher.(type) {
+	case *regexpMatcher:
+		re, err := syntax.Parse(m.expression, syntax.Perl)
+		if err != nil {
+			return nil
+		}
+		candidates = regexpSamples(re.Simplify())
+	case *dictionaryMatcher:
+		for _, terms := range m.terms {
+			candidates = append(candidates, terms...)
+		}
+		sort.Strings(candidates)
+	}
+
+	var samples []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if candidate == "" || seen[candidate] || len(samples) == maxOverlapSamples {
+			continue
+		}
+		seen[candidate] = true
+		if matches := l.Matches(candidate); len(matches) > 0 {
+			samples = append(samples, candidate)
+		}
+	}
+	return samples
+}
+
+// regexpSamples returns texts matching the regular expression: one for every alternative
+// and for optional parts both with and without them, up to maxOverlapSamples.
+func regexpSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		if r, ok := sampleRune(re.Rune); ok {
+			return []string{string(r)}
+		}
+		return nil
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"x"}
+	case syntax.OpCapture:
+		return regexpSamples(re.Sub[0])
+	case syntax.OpQuest:
+		return append([]string{""}, regexpSamples(re.Sub[0])...)
+	case syntax.OpStar, syntax.OpPlus:
+		return regexpSamples(re.Sub[0])
+	case syntax.OpRepeat:
+		n := re.Min
+		if n == 0 && re.Max != 0 {
+			n = 1
+		}
+		if n > maxSampleRepeat {
+			n = maxSampleRepeat
+		}
+		parts := make([]*syntax.Regexp, n)
+		for i := range parts {
+			parts[i] = re.Sub[0]
+		}
+		return concatSamples(parts)
+	case syntax.OpConcat:
+		return concatSamples(re.Sub)
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			samples = append(samples, regexpSamples(sub)...)
+		}
+		if len(samples) > maxOverlapSamples {
+			samples = samples[:maxOverlapSamples]
+		}
+		return samples
+	case syntax.OpNoMatch:
+		return nil
+	default:
+		// Anchors, word boundaries and empty matches take no text.
+		return []string{""}
+	}
+}
+
+// concatSamples returns the combinations of the samples of the parts, in order.
+func concatSamples(parts []*syntax.Regexp) []string {
+	samples := []string{""}
+	for _, part := range parts {
+		partSamples := regexpSamples(part)
+		var combined []string
+		for _, prefix := range samples {
+			for _, s := range partSamples {
+				if len(combined) < maxOverlapSamples {
+					combined = append(combined, prefix+s)
+				}
+			}
+		}
+		if len(combined) == 0 {
+			return nil
+		}
+		samples = combined
+	}
+	return samples
+}
+
+// sampleRune returns a rune of the character class, given as pairs of bounds, preferring
+// digits and letters to other printable characters.
+func sampleRune(ranges []rune) (rune, bool) {
+	for _, r := range "1aA" {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= r && r <= ranges[i+1] {
+				return r, true
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1] && r < ranges[i]+128; r++ {
+			if unicode.IsPrint(r) && !unicode.IsSpace(r) {
+				return r, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// logLinkOverlaps logs a warning for every overlap of the links.
+func logLinkOverlaps(links []*AutoLinker) {
+	for _, o := range analyzeOverlaps(links) {
+		mlog.Warn("Links overlap: "+o.warning().Error(),
+			mlog.String("kind", o.Kind),
+			mlog.String("link", o.Link.link.DisplayName()),
+			mlog.String("other_link", o.Other.link.DisplayName()))
+	}
+}
@@ -0,0 +1,83 @@
+package autolink
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compileLinks(t *testing.T, links ...*Link) []*AutoLinker {
+	var compiled []*AutoLinker
+	for _, l := range links {
+		al, err := NewAutoLinker(l, nil)
+		require.Nil(t, err)
+		compiled = append(compiled, al)
+	}
+	return compiled
+}
+
+func TestAnalyzeOverlaps(t *testing.T) {
+	jira := &Link{Name: "jira", Pattern: "(MM)(-)(?P<jira_id>\\d+)", Template: "[MM-$jira_id](https://mattermost.atlassian.net/browse/MM-$jira_id)"}
+
+	for name, tc := range map[string]struct {
+		links    []*Link
+		expected []string
+	}{
+		"duplicate": {
+			links:    []*Link{jira, {Name: "jira-copy", Pattern: "MM-(?P<id>\\d+)", Template: "[MM-$id](https://jira.example.com/MM-$id)"}},
+			expected: []string{`shadowed: "jira-copy" never applies, "jira" comes first and always links its matches, such as "MM-1"`},
+		},
+		"conflict": {
+			links: []*Link{jira, {Name: "tickets", Pattern: "(?P<project>MM|CLOUD)-(?P<id>\\d+)", Template: "[$project-$id](https://tickets.example.com/$project-$id)"}},
+			expected: []string{`conflict: "tickets" and "jira" both match "MM-1" and link it to different URLs, ` +
+				"https://tickets.example.com/MM-1 and https://mattermost.atlassian.net/browse/MM-1"},
+		},
+		"overlap": {
+			links: []*Link{
+				{Name: "cloud", Pattern: "(Mattermost Cloud)", Template: "[Mattermost Cloud](https://mattermost.com/cloud)"},
+				{Name: "mattermost", Pattern: "(Mattermost)", Template: "[Mattermost](https://mattermost.com)"},
+			},
+			expected: []string{`overlap: "mattermost" and "cloud" both match "Mattermost"`},
+		},
+		"dictionary": {
+			links: []*Link{
+				{Name: "glossary", Type: linkTypeDictionary, Dictionary: map[string]string{"DM": "direct message", "GM": "group message"}, Template: "**$term**"},
+				{Name: "dm", Pattern: "(DM)", Template: "**direct message**"},
+			},
+			expected: []string{`shadowed: "dm" never applies, "glossary" comes first and always links its matches, such as "DM"`},
+		},
+		"conditions": {
+			links: []*Link{
+				{Name: "jira-a", Pattern: "(MM-\\d+)", Template: "[$1](https://a.example.com/$1)", Conditions: &Conditions{TeamNames: []string{"a"}}},
+				{Name: "jira-b", Pattern: "(MM-\\d+)", Template: "[$1](https://b.example.com/$1)", Conditions: &Conditions{TeamNames: []string{"b"}}},
+			},
+		},
+		"distinct": {
+			links: []*Link{jira, {Name: "github", Pattern: "#(?P<issue>\\d+)", Template: "[#$issue](https://github.com/mattermost/mattermost-server/issues/$issue)"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var actual []string
+			for _, o := range analyzeOverlaps(compileLinks(t, tc.links...)) {
+				actual = append(actual, o.Kind+": "+o.warning().Error())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestRegexpSamples(t *testing.T) {
+	for pattern, expected := range map[string][]string{
+		"(MM)(-)(?P<jira_id>\\d+)": {"MM-1"},
+		"a(bb|cc)?":                {"a", "abb", "acc"},
+		"[^\\s]{3}x*":              {"111x"},
+		"^#[a-f0-9]{2,}$":          {"#11"},
+		"(?i)mattermost":           {"MATTERMOST"},
+	} {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		require.Nil(t, err)
+		assert.Equal(t, expected, regexpSamples(re.Simplify()), pattern)
+	}
+}
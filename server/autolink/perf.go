@@ -0,0 +1,221 @@
+package autolink
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+const (
+	// latencyBucketsPerDoubling is the number of buckets of a latency histogram between a
+	// duration and its double, which bounds the error of a percentile to about 19%.
+	latencyBucketsPerDoubling = 4
+
+	// latencyBuckets covers durations from 1µs to about 17s. Longer durations share the last
+	// bucket.
+	latencyBuckets = 24*latencyBucketsPerDoubling + 1
+)
+
+// latencyBounds holds the upper bound of every bucket of a latency histogram.
+var latencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, latencyBuckets)
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(time.Microsecond) * math.Pow(2, float64(i)/latencyBucketsPerDoubling))
+	}
+	return bounds
+}()
+
+// latencyHistogram counts durations in buckets of exponentially growing size, so that
+// percentiles can be estimated in constant memory.
+type latencyHistogram struct {
+	counts [latencyBuckets + 1]uint64
+	count  uint64
+	max    time.Duration
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(latencyBounds), func(i int) bool {
+		return d <= latencyBounds[i]
+	})
+	h.counts[i]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns an estimate of the duration that the fraction q of the observations do
+// not exceed: the upper bound of its bucket, or the longest duration observed if lower.
+func (h *latencyHistogram) percentile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		if i < len(latencyBounds) && latencyBounds[i] < h.max {
+			return latencyBounds[i]
+		}
+		break
+	}
+	return h.max
+}
+
+// perfProfile collects the time spent linking posts since the plugin started: in total for
+// every post, and by every link.
+type perfProfile struct {
+	lock  sync.Mutex
+	since time.Time
+	posts latencyHistogram
+	links map[string]*latencyHistogram
+}
+
+func newPerfProfile() *perfProfile {
+	return &perfProfile{
+		since: time.Now(),
+		links: make(map[string]*latencyHistogram),
+	}
+}
+
+// record adds the time spent linking a post, and by every link that was applied to it.
+func (pp *perfProfile) record(total time.Duration, elapsed map[*AutoLinker]time.Duration) {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	pp.posts.observe(total)
+	for l, d := range elapsed {
+		name := l.link.DisplayName()
+		h := pp.links[name]
+		if h == nil {
+			h = &latencyHistogram{}
+			pp.links[name] = h
+		}
+		h.observe(d)
+	}
+}
+
+// perfStats summarizes the durations of a histogram.
+type perfStats struct {
+	Name          string
+	Count         uint64
+	P50, P95, P99 time.Duration
+	Max           time.Duration
+}
+
+func newPerfStats(name string, h *latencyHistogram) *perfStats {
+	return &perfStats{
+		Name:  name,
+		Count: h.count,
+		P50:   h.percentile(0.50),
+		P95:   h.percentile(0.95),
+		P99:   h.percentile(0.99),
+		Max:   h.max,
+	}
+}
+
+// stats returns the statistics of whole posts, and of every link, slowest first.
+func (pp *perfProfile) stats() (*perfStats, []*perfStats) {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	var links []*perfStats
+	for name, h := range pp.links {
+		links = append(links, newPerfStats(name, h))
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].P99 != links[j].P99 {
+			return links[i].P99 > links[j].P99
+		}
+		return links[i].Name < links[j].Name
+	})
+	return newPerfStats("", &pp.posts), links
+}
+
+// getPerfProfile returns the profile of the plugin, creating it on first use.
+func (p *Plugin) getPerfProfile() *perfProfile {
+	p.perfOnce.Do(func() {
+		p.perf = newPerfProfile()
+	})
+	return p.perf
+}
+
+// profilePost records the time spent linking a post, and logs the links that took longer
+// than the configured threshold.
+func (p *Plugin) profilePost(total time.Duration, elapsed map[*AutoLinker]time.Duration, messageLength int) {
+	p.getPerfProfile().record(total, elapsed)
+
+	threshold := p.getConfiguration().slowLinkThreshold()
+	for l, d := range elapsed {
+		if d > threshold {
+			mlog.Warn("Link was slow to apply",
+				mlog.String("link", l.link.DisplayName()),
+				mlog.String("pattern", l.link.Pattern),
+				mlog.Int64("duration_ms", int64(d/time.Millisecond)),
+				mlog.Int("message_length", messageLength))
+		}
+	}
+}
+
+// executePerfCommand handles
+//
+//	/autolink perf
+//
+// It shows the percentiles of the time spent linking posts since the plugin started, in
+// total and by link.
+func (p *Plugin) executePerfCommand(T i18n.TranslateFunc) string {
+	pp := p.getPerfProfile()
+	posts, links := pp.stats()
+	if posts.Count == 0 {
+		return T("autolink.command.perf.empty", map[string]interface{}{"Since": formatMillis(pp.since.UnixNano() / int64(time.Millisecond))})
+	}
+
+	row := func(name string, s *perfStats) string {
+		return "\n" + T("autolink.command.perf.row", map[string]interface{}{
+			"Link":  name,
+			"Count": s.Count,
+			"P50":   formatLatency(s.P50),
+			"P95":   formatLatency(s.P95),
+			"P99":   formatLatency(s.P99),
+			"Max":   formatLatency(s.Max),
+		})
+	}
+
+	text := T("autolink.command.perf.header", map[string]interface{}{
+		"Since":     formatMillis(pp.since.UnixNano() / int64(time.Millisecond)),
+		"Threshold": formatLatency(p.getConfiguration().slowLinkThreshold()),
+	})
+	text += "\n\n" + T("autolink.command.perf.columns")
+	text += row(T("autolink.command.perf.posts"), posts)
+	for _, s := range links {
+		text += row(s.Name, s)
+	}
+	return text
+}
+
+// formatLatency rounds a duration to three significant digits.
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= 100*time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	case d >= 10*time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,82 @@
+package autolink
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := &latencyHistogram{}
+	assert.Equal(t, time.Duration(0), h.percentile(0.5))
+
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, uint64(100), h.count)
+	assert.Equal(t, 100*time.Millisecond, h.max)
+	assert.Equal(t, 100*time.Millisecond, h.percentile(1))
+
+	// Percentiles are the upper bound of their bucket, at most about 19% above the exact value.
+	for q, exact := range map[float64]time.Duration{0.5: 50 * time.Millisecond, 0.95: 95 * time.Millisecond, 0.99: 99 * time.Millisecond} {
+		p := h.percentile(q)
+		assert.True(t, p >= exact && float64(p) <= 1.19*float64(exact), "p%v = %v", q*100, p)
+	}
+
+	h = &latencyHistogram{}
+	h.observe(time.Hour)
+	assert.Equal(t, time.Hour, h.percentile(0.5))
+}
+
+func TestPerfProfile(t *testing.T) {
+	links := compileLinks(t, &Link{Name: "fast", Pattern: "(a)", Template: "b"}, &Link{Name: "slow", Pattern: "(c)", Template: "d"})
+
+	pp := newPerfProfile()
+	pp.record(3*time.Millisecond, map[*AutoLinker]time.Duration{links[0]: time.Millisecond, links[1]: 2 * time.Millisecond})
+	pp.record(time.Millisecond, map[*AutoLinker]time.Duration{links[0]: time.Millisecond})
+
+	posts, stats := pp.stats()
+	assert.Equal(t, uint64(2), posts.Count)
+	assert.Equal(t, 3*time.Millisecond, posts.Max)
+	require.Len(t, stats, 2)
+	assert.Equal(t, "slow", stats[0].Name)
+	assert.Equal(t, uint64(1), stats[0].Count)
+	assert.Equal(t, "fast", stats[1].Name)
+	assert.Equal(t, uint64(2), stats[1].Count)
+}
+
+func TestPerfCommand(t *testing.T) {
+	p := setupGoldenPlugin(t, `{"Links": [{"Name": "jira", "Pattern": "(?P<id>MM-\\d+)", "Template": "[${id}](https://mattermost.atlassian.net/browse/${id})"}]}`)
+	api := p.API.(*plugintest.API)
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", "admin").Return(&model.User{Locale: "en"}, nil)
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink perf"})
+	assert.True(t, strings.HasPrefix(resp.Text, "No posts were linked on this node since "), resp.Text)
+
+	for _, message := range []string{"See MM-1", "Nothing to link"} {
+		p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user", ChannelId: "channel", Message: message})
+	}
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink perf"})
+	lines := strings.Split(resp.Text, "\n")
+	require.Len(t, lines, 6)
+	assert.Contains(t, lines[0], "Links taking longer than 100ms on a post are logged.")
+	assert.Equal(t, "| Link | Posts | p50 | p95 | p99 | Max |", lines[2])
+	assert.True(t, strings.HasPrefix(lines[4], "| *All links* | 2 | "), lines[4])
+	assert.True(t, strings.HasPrefix(lines[5], "| jira | 2 | "), lines[5])
+}
+
+func TestFormatLatency(t *testing.T) {
+	assert.Equal(t, "12µs", formatLatency(12345*time.Nanosecond))
+	assert.Equal(t, "1.23ms", formatLatency(1234567*time.Nanosecond))
+	assert.Equal(t, "12.3ms", formatLatency(12345678*time.Nanosecond))
+	assert.Equal(t, "123ms", formatLatency(123456789*time.Nanosecond))
+	assert.Equal(t, "1.23s", formatLatency(1234567890*time.Nanosecond))
+}
@@ -0,0 +1,669 @@
+package autolink
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/mlog"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/utils/markdown"
+)
+
+// Plugin the main struct for everything
+type Plugin struct {
+	plugin.MattermostPlugin
+
+	links         atomic.Value
+	configuration atomic.Value
+
+	// configurationLock serializes changes to the links made through the API.
+	configurationLock sync.Mutex
+
+	// rewritten writes the index of rewritten posts of this node.
+	rewritten *rewrittenIndexer
+
+	// syncLock serializes loading revisions of the links from the KV store, so that an older
+	// revision never replaces a newer one.
+	syncLock sync.Mutex
+
+	webhooks *webhookDispatcher
+	lookups  *lookupCache
+	linkSync *linkSyncer
+
+	node     *nodeReporter
+	nodeOnce sync.Once
+
+	perf     *perfProfile
+	perfOnce sync.Once
+}
+
+// OnConfigurationChange is invoked when configuration changes may have been made.
+func (p *Plugin) OnConfigurationChange() error {
+	var c Configuration
+	err := p.API.LoadPluginConfiguration(&c)
+	if err != nil {
+		return err
+	}
+
+	fromVersion := c.Version
+	migrations, err := migrateConfiguration(&c)
+	if err != nil {
+		return err
+	}
+	if len(migrations) > 0 {
+		mlog.Info("Migrated the configuration, it is saved in the new version when links are next changed through the API",
+			mlog.Int("from_version", fromVersion),
+			mlog.Int("to_version", c.Version),
+			mlog.String("migrations", strings.Join(migrations, ", ")))
+	}
+
+	switch c.Storage {
+	case "", storageConfig:
+	case storageKV:
+		p.syncLock.Lock()
+		defer p.syncLock.Unlock()
+
+		// The links of the configuration are only imported when the KV store holds none yet.
+		rev, err := p.activeLinkRevision(&c)
+		if err != nil {
+			return err
+		}
+		c.Links = rev.Links
+		c.revision, c.stamp = rev.Revision, rev.Stamp
+	default:
+		return newLocalizedError("autolink.storage.unknown", map[string]interface{}{"Storage": c.Storage})
+	}
+
+	p.applyConfiguration(&c)
+	return nil
+}
+
+// applyConfiguration compiles the links of the configuration and makes them active.
+func (p *Plugin) applyConfiguration(c *Configuration) {
+	links := make([]*AutoLinker, 0)
+
+	for _, l := range c.Links {
+		al, lerr := NewAutoLinker(l, c)
+		if lerr != nil {
+			mlog.Error("Error creating autolinker: "+lerr.Error(), mlog.String("link", l.DisplayName()))
+			continue
+		}
+
+		links = append(links, al)
+	}
+
+	p.links.Store(links)
+	p.configuration.Store(c)
+
+	// The analysis takes a while with many links, and only informs the administrator.
+	go logLinkOverlaps(links)
+
+	if c.Storage == storageKV {
+		p.getNodeReporter().report(c.revision, true)
+	}
+}
+
+// getConfiguration returns the most recently loaded configuration.
+func (p *Plugin) getConfiguration() *Configuration {
+	c, ok := p.configuration.Load().(*Configuration)
+	if !ok {
+		return &Configuration{}
+	}
+	return c
+}
+
+// saveLinks replaces the links. With the config storage, they are saved in the server
+// configuration and the server notifies the plugin of the change through
+// OnConfigurationChange. With the kv storage, they are saved as a new revision described by
+// change, which is applied right away.
+func (p *Plugin) saveLinks(links []*Link, change *linkRevision) error {
+	if conf := p.getConfiguration(); conf.Storage == storageKV {
+		p.syncLock.Lock()
+		defer p.syncLock.Unlock()
+
+		if err := p.storeLinkRevision(links, change); err != nil {
+			return err
+		}
+		c := *p.getConfiguration()
+		c.Links = links
+		c.revision, c.stamp = change.Revision, change.Stamp
+		p.applyConfiguration(&c)
+		return nil
+	}
+
+	config := p.API.GetConfig()
+	if config.PluginSettings.Plugins == nil {
+		config.PluginSettings.Plugins = make(map[string]map[string]interface{})
+	}
+
+	settings := config.PluginSettings.Plugins[manifest.Id]
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+
+	// The links are saved as migrated, so the version and the migrations that ran are saved
+	// along with them.
+	conf := p.getConfiguration()
+	settings[settingKey(settings, "links")] = links
+	settings[settingKey(settings, "version")] = currentConfigurationVersion()
+	settings[settingKey(settings, "migrations")] = conf.Migrations
+	config.PluginSettings.Plugins[manifest.Id] = settings
+
+	if appErr := p.API.SaveConfig(config); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// settingKey returns the key of the setting in the plugin settings, which may differ in case
+// from the name of the setting, or the name itself if it is not set.
+func settingKey(settings map[string]interface{}, name string) string {
+	for k := range settings {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+// MessageWillBePosted is invoked when a message is posted by a user before it is committed
+// to the database.
+func (p *Plugin) MessageWillBePosted(c *plugin.Context, post *model.Post) (*model.Post, string) {
+	start := time.Now()
+	messageLength := len(post.Message)
+	removePluginProps(post)
+
+	trace := p.newPostTrace(post)
+
+	allLinks := p.links.Load().([]*AutoLinker)
+	links := p.applicableLinks(post, allLinks)
+	trace.links(allLinks, links)
+
+	budget := newProcessingBudget(p.getConfiguration())
+	budget.trace = trace
+	result := p.linkMessage(post, links, budget)
+	if result != nil && len(result.applied) > 0 {
+		addEntitiesProp(post, result.entities)
+		p.keepOriginalMessage(post, result.applied)
+		post.Message = result.message
+		p.holdWebhookEvents(post, result.entities)
+		trace.outcome(traceLinked)
+	}
+
+	p.profilePost(time.Since(start), budget.elapsed, messageLength)
+	trace.finish()
+	return post, ""
+}
+
+// linkMessage applies the links to the message of the post as it is saved, without changing
+// the post. It returns nil if the message is to be saved unchanged.
+func (p *Plugin) linkMessage(post *model.Post, links []*AutoLinker, budget *processingBudget) *replaceResult {
+	result := p.replaceLinks(post.Message, links, budget)
+	if result.exhaustedBy != nil {
+		mlog.Warn("Post processing budget exhausted, leaving the message unchanged",
+			mlog.String("link", result.exhaustedBy.link.DisplayName()),
+			mlog.String("pattern", result.exhaustedBy.link.Pattern),
+			mlog.String("user_id", post.UserId),
+			mlog.String("channel_id", post.ChannelId),
+			mlog.Int("message_length", len(post.Message)))
+		budget.trace.outcome(traceExhausted)
+		return nil
+	}
+	if fitsPost(result.message) {
+		return result
+	}
+
+	// Linking made the message too long to be saved. The replacements already made are put
+	// together again without the last configured links, keeping as many links as fit.
+	n := sort.Search(len(links), func(n int) bool {
+		return !fitsPost(result.withLinks(n + 1).message)
+	})
+	if n > 0 {
+		partial := result.withLinks(n)
+		if partial.keepsStructure() {
+			mlog.Warn("Linked message was too long, some links were not applied",
+				mlog.String("user_id", post.UserId),
+				mlog.String("channel_id", post.ChannelId),
+				mlog.Int("applied_links", n),
+				mlog.Int("total_links", len(links)))
+			return partial
+		}
+	}
+
+	mlog.Warn("Linked message was too long, leaving the message unchanged",
+		mlog.String("user_id", post.UserId),
+		mlog.String("channel_id", post.ChannelId),
+		mlog.Int("message_length", len(post.Message)),
+		mlog.Int("linked_length", len(result.message)))
+	budget.trace.outcome(traceTooLong)
+	return nil
+}
+
+// fitsPost reports whether the message is short enough to be saved.
+func fitsPost(message string) bool {
+	return utf8.RuneCountInString(message) <= model.POST_MESSAGE_MAX_RUNES_V2
+}
+
+// MessageHasBeenPosted is invoked after the message has been committed to the database.
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	p.indexRewrittenPost(post)
+	p.sendWebhookEvents(post)
+}
+
+// replaceResult is the outcome of applying the links to a message.
+type replaceResult struct {
+	message string
+
+	// applied lists the links that changed the message, in configuration order.
+	applied []*AutoLinker
+
+	// entities describes every match made in the message.
+	entities []*Entity
+
+	// discarded lists the links whose replacements were left out because they changed the
+	// structure of the rest of the message, in configuration order.
+	discarded []*AutoLinker
+
+	// exhaustedBy is the link that was running when the processing budget ran out. The
+	// message should be left unchanged if it is set.
+	exhaustedBy *AutoLinker
+
+	// original is the message before the links were applied, and runs are the runs of text
+	// nodes the links changed, in order, to apply fewer of the links without matching again.
+	original string
+	links    []*AutoLinker
+	runs     []*linkedRun
+}
+
+// linkedRun is a run of text nodes of the original message, from start to end, that the
+// links changed.
+type linkedRun struct {
+	start, end int
+
+	// stages holds the source of the run after every link that changed it, in configuration
+	// order.
+	stages []*linkStage
+}
+
+type linkStage struct {
+	link     *AutoLinker
+	text     string
+	entities []*Entity
+
+	// matches are the matches the link replaced, in the text it was applied to, in the
+	// order of entities. attempt traces the link, if the post is traced.
+	matches []*Match
+	attempt *attemptTrace
+}
+
+// withLinks returns the result of applying only the first n of the links, put together from
+// the replacements that were made.
+func (r *replaceResult) withLinks(n int) *replaceResult {
+	included := make(map[*AutoLinker]bool, n)
+	for _, l := range r.links[:n] {
+		included[l] = true
+	}
+
+	partial := &replaceResult{original: r.original, links: r.links[:n]}
+	applied := make(map[*AutoLinker]bool)
+	var message strings.Builder
+	pos := 0
+	for _, run := range r.runs {
+		k := 0
+		for k < len(run.stages) && included[run.stages[k].link] {
+			applied[run.stages[k].link] = true
+			partial.entities = append(partial.entities, run.stages[k].entities...)
+			k++
+		}
+		if k == 0 {
+			continue
+		}
+		message.WriteString(r.original[pos:run.start])
+		message.WriteString(run.stages[k-1].text)
+		pos = run.end
+		partial.runs = append(partial.runs, &linkedRun{start: run.start, end: run.end, stages: run.stages[:k]})
+	}
+	message.WriteString(r.original[pos:])
+	partial.message = message.String()
+
+	for _, l := range partial.links {
+		if applied[l] {
+			partial.applied = append(partial.applied, l)
+		}
+	}
+	for _, l := range r.discarded {
+		if included[l] {
+			partial.discarded = append(partial.discarded, l)
+		}
+	}
+	return partial
+}
+
+// keepsStructure reports whether the replacements leave the structure of the rest of the
+// original message as it was. Every replacement was checked as it was made, along with the
+// others made before it, but leaving some of them out may still change the structure.
+func (r *replaceResult) keepsStructure() bool {
+	ranges := make([]replacedRange, 0, len(r.runs))
+	for _, run := range r.runs {
+		ranges = append(ranges, replacedRange{start: run.start, end: run.end, size: len(run.stages[len(run.stages)-1].text)})
+	}
+	return parseMarkdownStructure(r.original).sameOutside(parseMarkdownStructure(r.message), ranges)
+}
+
+// linkedText is the outcome of applying the links to the text of a text node.
+type linkedText struct {
+	text        string
+	applied     []*AutoLinker
+	entities    []*Entity
+	discarded   []*AutoLinker
+	exhaustedBy *AutoLinker
+
+	// stages holds the text after every applied link.
+	stages []*linkStage
+
+	// attempts traces the links tried on the text, if the post is traced.
+	attempts []*attemptTrace
+}
+
+// linkText applies the links to the text of a run of text nodes, one after the other, and
+// returns its source with the replacements.
+func (p *Plugin) linkText(source *escapedText, links []*AutoLinker, budget *processingBudget) *linkedText {
+	linked := &linkedText{}
+	exhaust := func(l *AutoLinker) {
+		linked.exhaustedBy = l
+		if budget.trace != nil {
+			linked.attempts = append(linked.attempts, budget.trace.exhaustedAttempt(l))
+		}
+	}
+	for _, l := range links {
+		// The budget is checked before matching, so that no link runs once it is spent,
+		// and after, so that a link that spends it is the one reported.
+		if !budget.spend(len(source.text)) {
+			exhaust(l)
+			break
+		}
+		start := time.Now()
+		matches := source.aligned(l.Matches(source.text))
+		if budget.expired() {
+			budget.charge(l, time.Since(start))
+			exhaust(l)
+			break
+		}
+		var attempt *attemptTrace
+		if budget.trace != nil {
+			attempt = budget.trace.attempt(l, source, matches)
+			linked.attempts = append(linked.attempts, attempt)
+		}
+		if len(matches) == 0 {
+			budget.charge(l, time.Since(start))
+			continue
+		}
+
+		for _, m := range matches {
+			p.lookupMatch(l, m, budget)
+		}
+		source = source.replace(matches)
+		budget.charge(l, time.Since(start))
+
+		stage := &linkStage{link: l, text: source.raw, matches: matches, attempt: attempt}
+		for _, m := range matches {
+			stage.entities = append(stage.entities, newEntity(l, m))
+		}
+		linked.applied = append(linked.applied, l)
+		linked.entities = append(linked.entities, stage.entities...)
+		linked.stages = append(linked.stages, stage)
+	}
+	linked.text = source.raw
+	return linked
+}
+
+// relink applies the stages of a run to its source again, without matching, and keeps the
+// replacements of a link only if keep accepts the source they produce. The matches of the
+// links after one that was left out are moved to the text without its replacements, and
+// dropped where they were made in them.
+func relink(source *escapedText, stages []*linkStage, keep func(raw string) bool) *linkedText {
+	linked := &linkedText{}
+
+	// positions holds the position in source of every byte of the text the stage was
+	// applied to, followed by the length of source, or -1 for the bytes of replacements
+	// that were left out.
+	positions := make([]int, len(source.text)+1)
+	for i := range positions {
+		positions[i] = i
+	}
+	for _, stage := range stages {
+		moved := make([]*Match, len(stage.matches))
+		var matches []*Match
+		var entities []*Entity
+		for i, m := range stage.matches {
+			if moved[i] = moveMatch(m, positions); moved[i] != nil {
+				matches = append(matches, moved[i])
+				entities = append(entities, stage.entities[i])
+			}
+		}
+		if len(matches) == 0 {
+			positions = movePositions(positions, stage.matches, moved)
+			continue
+		}
+
+		replaced := source.replace(matches)
+		if !keep(replaced.raw) {
+			stage.attempt.discard()
+			linked.discarded = append(linked.discarded, stage.link)
+			positions = movePositions(positions, stage.matches, make([]*Match, len(stage.matches)))
+			continue
+		}
+
+		source = replaced
+		positions = movePositions(positions, stage.matches, moved)
+		linked.applied = append(linked.applied, stage.link)
+		linked.entities = append(linked.entities, entities...)
+		linked.stages = append(linked.stages, &linkStage{link: stage.link, text: source.raw, entities: entities, matches: matches, attempt: stage.attempt})
+	}
+	linked.text = source.raw
+	return linked
+}
+
+// moveMatch returns the match at the positions the bytes it replaced have in the source, or
+// nil if some of them have none.
+func moveMatch(m *Match, positions []int) *Match {
+	start := positions[m.Start]
+	if start < 0 {
+		return nil
+	}
+	for i := m.Start; i < m.End; i++ {
+		if positions[i] != start+i-m.Start {
+			return nil
+		}
+	}
+	moved := *m
+	moved.Start, moved.End = start, start+m.End-m.Start
+	return &moved
+}
+
+// movePositions returns the positions of the text after the matches were replaced in it. The
+// bytes of a replacement have a position if the match was also replaced in the source, at
+// the position given by moved.
+func movePositions(positions []int, matches, moved []*Match) []int {
+	next := make([]int, 0, len(positions))
+	shift := 0
+	copyPositions := func(from []int) {
+		for _, p := range from {
+			if p >= 0 {
+				p += shift
+			}
+			next = append(next, p)
+		}
+	}
+
+	pos := 0
+	for i, m := range matches {
+		copyPositions(positions[pos:m.Start])
+		for j := 0; j < len(m.Replacement); j++ {
+			if moved[i] == nil {
+				next = append(next, -1)
+			} else {
+				next = append(next, moved[i].Start+shift+j)
+			}
+		}
+		if moved[i] != nil {
+			shift += len(m.Replacement) - (m.End - m.Start)
+		}
+		pos = m.End
+	}
+	copyPositions(positions[pos:])
+	return next
+}
+
+// replaceLinks applies the links to every text node of the message.
+func (p *Plugin) replaceLinks(message string, links []*AutoLinker, budget *processingBudget) *replaceResult {
+	result := &replaceResult{original: message, links: links}
+	applied := make(map[*AutoLinker]bool)
+	discarded := make(map[*AutoLinker]bool)
+
+	// The parser makes a text node of every backslash escape and character reference, so
+	// the links are applied to runs of adjacent text nodes, to match terms containing them.
+	trace := budget.trace
+	var runs [][]*markdown.Text
+	var runTraces []*nodeTrace
+	var run []*markdown.Text
+	endRun := func() {
+		if run == nil {
+			return
+		}
+		runs = append(runs, run)
+		if trace != nil {
+			var text string
+			for _, t := range run {
+				text += t.Text
+			}
+			runTraces = append(runTraces, trace.textNode(run[0].Range.Position, run[len(run)-1].Range.End, text))
+		}
+		run = nil
+	}
+	markdown.Inspect(message, func(node interface{}) bool {
+		switch v := node.(type) {
+		case nil:
+			return true
+		case *markdown.Text:
+			run = append(run, v)
+			return true
+		}
+		endRun()
+
+		switch node.(type) {
+		// never descend into the text content of a link/image
+		case *markdown.InlineLink, *markdown.InlineImage, *markdown.ReferenceLink, *markdown.ReferenceImage, *markdown.Autolink:
+			trace.skipNode(node, traceSkippedLink)
+			return false
+		case *markdown.CodeSpan, *markdown.FencedCode, *markdown.IndentedCode:
+			trace.skipNode(node, traceSkippedCode)
+		}
+		return true
+	})
+	endRun()
+
+	type linkedSource struct {
+		source *escapedText
+		start  int
+		linked *linkedText
+	}
+	var linkedSources []*linkedSource
+	for i, run := range runs {
+		var runTrace *nodeTrace
+		if trace != nil {
+			runTrace = runTraces[i]
+		}
+
+		source, start, ok := textRunSource(message, run)
+		if !ok {
+			runTrace.skip(traceSkippedMismatch)
+			var text string
+			for _, t := range run {
+				text += t.Text
+			}
+			mlog.Error(fmt.Sprintf("Markdown text did not match its source, '%s' at %d", text, run[0].Range.Position))
+			continue
+		}
+
+		linked := p.linkText(source, links, budget)
+		runTrace.tried(linked.attempts)
+		if linked.exhaustedBy != nil {
+			result.exhaustedBy = linked.exhaustedBy
+			break
+		}
+		if linked.text != source.raw {
+			linkedSources = append(linkedSources, &linkedSource{source: source, start: start, linked: linked})
+		}
+	}
+
+	// A replacement leaving a code span or a link open would change how the rest of the
+	// message parses. The runs are checked one at a time, and the links of a run one at a
+	// time, to leave out the ones responsible, only if all the replacements together do.
+	postText := message
+	offset := 0
+	add := func(ls *linkedSource, linked *linkedText) {
+		for _, l := range linked.discarded {
+			mlog.Warn("Replacement changed the structure of the rest of the message, leaving the text unlinked",
+				mlog.String("link", l.link.DisplayName()),
+				mlog.String("text", ls.source.text))
+			discarded[l] = true
+		}
+		for _, l := range linked.applied {
+			applied[l] = true
+		}
+		result.entities = append(result.entities, linked.entities...)
+
+		if linked.text != ls.source.raw {
+			startPos, endPos := ls.start+offset, ls.start+offset+len(ls.source.raw)
+			result.runs = append(result.runs, &linkedRun{start: ls.start, end: ls.start + len(ls.source.raw), stages: linked.stages})
+			postText = postText[:startPos] + linked.text + postText[endPos:]
+			offset += len(linked.text) - len(ls.source.raw)
+		}
+	}
+	for _, ls := range linkedSources {
+		add(ls, ls.linked)
+	}
+	result.message = postText
+	if result.exhaustedBy != nil || len(result.runs) == 0 || result.keepsStructure() {
+		return result.withApplied(applied, discarded)
+	}
+
+	guard := &structureGuard{}
+	postText, offset = message, 0
+	result.entities, result.runs = nil, nil
+	applied = make(map[*AutoLinker]bool)
+	for _, ls := range linkedSources {
+		startPos, endPos := ls.start+offset, ls.start+offset+len(ls.source.raw)
+		keep := func(text string) bool {
+			return guard.keeps(postText, startPos, endPos, text)
+		}
+		linked := ls.linked
+		if !keep(linked.text) {
+			linked = relink(ls.source, linked.stages, keep)
+		}
+		add(ls, linked)
+	}
+	result.message = postText
+	return result.withApplied(applied, discarded)
+}
+
+// withApplied sets the links that were applied and discarded, in configuration order.
+func (r *replaceResult) withApplied(applied, discarded map[*AutoLinker]bool) *replaceResult {
+	for _, l := range r.links {
+		if applied[l] {
+			r.applied = append(r.applied, l)
+		}
+		if discarded[l] {
+			r.discarded = append(r.discarded, l)
+		}
+	}
+	return r
+}
@@ -1,7 +1,11 @@
-package main
+package autolink
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/mattermost/mattermost-server/model"
 	"github.com/mattermost/mattermost-server/plugin"
@@ -16,11 +20,11 @@ func TestPlugin(t *testing.T) {
 		Pattern:  "(Mattermost)",
 		Template: "[Mattermost](https://mattermost.com)",
 	})
-	validConfiguration := Configuration{links}
+	validConfiguration := Configuration{Links: links}
 
 	api := &plugintest.API{}
 
-	api.On("LoadPluginConfiguration", mock.AnythingOfType("*main.Configuration")).Return(func(dest interface{}) error {
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
 		*dest.(*Configuration) = validConfiguration
 		return nil
 	})
@@ -47,11 +51,11 @@ func TestSpecialCases(t *testing.T) {
 		Pattern:  "(foo!bar)",
 		Template: "fb",
 	})
-	validConfiguration := Configuration{links}
+	validConfiguration := Configuration{Links: links}
 
 	api := &plugintest.API{}
 
-	api.On("LoadPluginConfiguration", mock.AnythingOfType("*main.Configuration")).Return(func(dest interface{}) error {
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
 		*dest.(*Configuration) = validConfiguration
 		return nil
 	})
@@ -173,3 +177,134 @@ func TestSpecialCases(t *testing.T) {
 		assert.Equal(t, tt.expectedMessage, rpost.Message)
 	}
 }
+
+func TestMaxPostSize(t *testing.T) {
+	links := []*Link{{
+		Pattern:  "(Alpha)",
+		Template: "[Alpha](https://a.com)",
+	}, {
+		Pattern:  "(Beta)",
+		Template: "[Beta](https://beta.example.com/" + strings.Repeat("b", 100) + ")",
+	}}
+	validConfiguration := Configuration{Links: links}
+
+	api := &plugintest.API{}
+
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = validConfiguration
+		return nil
+	})
+
+	api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.OnConfigurationChange()
+
+	var tests = []struct {
+		inputMessage    string
+		expectedMessage string
+	}{
+		{
+			"Alpha Beta",
+			"[Alpha](https://a.com) [Beta](https://beta.example.com/" + strings.Repeat("b", 100) + ")",
+		}, {
+			strings.Repeat("x", 16300) + " Alpha Beta",
+			strings.Repeat("x", 16300) + " [Alpha](https://a.com) Beta",
+		}, {
+			strings.Repeat("x", 16370) + " Alpha Beta",
+			strings.Repeat("x", 16370) + " Alpha Beta",
+		},
+	}
+
+	for _, tt := range tests {
+		post := &model.Post{
+			Message: tt.inputMessage,
+		}
+
+		rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+
+		assert.Equal(t, tt.expectedMessage, rpost.Message)
+	}
+}
+
+func TestMaxPostSizeManyLinks(t *testing.T) {
+	var links []*Link
+	var words []string
+	for i := 0; i < 200; i++ {
+		word := "T" + strconv.Itoa(i)
+		links = append(links, &Link{
+			Name:     word,
+			Pattern:  "(" + word + ")",
+			Template: "[" + word + "](https://example.com/" + strings.Repeat("t", 100) + ")",
+		})
+		words = append(words, word)
+	}
+	// The race detector slows matching down too much for the default budget.
+	validConfiguration := Configuration{Links: links, MaxPostProcessingMilliseconds: 60000}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = validConfiguration
+		return nil
+	})
+	api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+
+	p := Plugin{}
+	p.SetAPI(api)
+	p.OnConfigurationChange()
+
+	// Every link is matched once, and the links that fit are kept from the replacements
+	// made, without running out of the processing budget.
+	post := &model.Post{Message: strings.Join(words, " ")}
+	rpost, _ := p.MessageWillBePosted(&plugin.Context{}, post)
+
+	applied := strings.Count(rpost.Message, "](https://example.com/")
+	linkLength := len(links[0].Template) - len("T0")
+	assert.True(t, applied > 100, "applied %d links", applied)
+	assert.True(t, utf8.RuneCountInString(rpost.Message) <= model.POST_MESSAGE_MAX_RUNES_V2)
+	assert.True(t, utf8.RuneCountInString(rpost.Message)+linkLength > model.POST_MESSAGE_MAX_RUNES_V2)
+	assert.Contains(t, rpost.Message, "[T0](https://example.com/")
+	assert.Contains(t, rpost.Message, " T199")
+}
+
+// mockKVStore backs the KV methods of the API mock with a map.
+func mockKVStore(api *plugintest.API) map[string][]byte {
+	store := make(map[string][]byte)
+	mockSharedKVStore(api, store)
+	return store
+}
+
+// mockSharedKVStore backs the KV methods of the API mock with the given map, which may be
+// shared by the plugins of several nodes.
+func mockSharedKVStore(api *plugintest.API, store map[string][]byte) {
+	api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(func(key string, value []byte) *model.AppError {
+		store[key] = value
+		return nil
+	})
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) []byte {
+		return store[key]
+	}, nil)
+	api.On("KVDelete", mock.AnythingOfType("string")).Return(func(key string) *model.AppError {
+		delete(store, key)
+		return nil
+	})
+}
+
+// mockPostStore backs the post methods of the API mock with a map.
+func mockPostStore(api *plugintest.API) map[string]*model.Post {
+	posts := make(map[string]*model.Post)
+	api.On("GetPost", mock.AnythingOfType("string")).Return(func(id string) *model.Post {
+		return posts[id]
+	}, func(id string) *model.AppError {
+		if posts[id] == nil {
+			return model.NewAppError("GetPost", "app.post.get.app_error", nil, "", http.StatusNotFound)
+		}
+		return nil
+	})
+	api.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(func(post *model.Post) *model.Post {
+		posts[post.Id] = post
+		return post
+	}, nil)
+	return posts
+}
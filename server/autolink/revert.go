@@ -0,0 +1,570 @@
+package autolink
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+const (
+	// pluginPropPrefix starts the names of the post props set by the plugin.
+	pluginPropPrefix = "autolink_"
+
+	// propOriginalMessage holds the message as written by the author of a rewritten post.
+	propOriginalMessage = "autolink_original_message"
+	// propOriginalMessageKey points to the KV entry holding the original message when it is
+	// too long to be kept in the post props.
+	propOriginalMessageKey = "autolink_original_message_key"
+	// propLinks lists the names of the links that changed a post.
+	propLinks = "autolink_links"
+
+	originalMessageKeyPrefix = "original_"
+	rewrittenPostsKeyPrefix  = "rewritten_"
+
+	// rewrittenNodesKey lists the nodes that indexed rewritten posts, and rewrittenSinceKey
+	// holds when the first post was indexed.
+	rewrittenNodesKey = "rewritten_nodes"
+	rewrittenSinceKey = "rewritten_since"
+
+	// maxQueuedRewrittenPosts bounds the entries waiting to be added to the index.
+	maxQueuedRewrittenPosts = 1000
+
+	// maxRewrittenPostsPerKey bounds the entries of the index kept under a single key. Further
+	// entries are kept under the next part.
+	maxRewrittenPostsPerKey = 1000
+
+	rewrittenDayFormat = "2006-01-02"
+)
+
+var (
+	errPostNotRewritten = newLocalizedError("autolink.revert.not_rewritten", nil)
+	errPostEdited       = newLocalizedError("autolink.revert.edited", nil)
+)
+
+// rewrittenPost is an entry in the index of posts changed by a link.
+type rewrittenPost struct {
+	PostId   string
+	CreateAt int64
+}
+
+// keepOriginalMessage records the message as written by the author and the links that
+// changed it, so that the rewrite can be reverted later.
+func (p *Plugin) keepOriginalMessage(post *model.Post, applied []*AutoLinker) {
+	names := make([]string, 0, len(applied))
+	for _, l := range applied {
+		names = append(names, l.link.DisplayName())
+	}
+	post.AddProp(propLinks, names)
+
+	// Post props are limited in size, so long messages are kept in the KV store instead.
+	post.AddProp(propOriginalMessage, post.Message)
+	if utf8.RuneCountInString(model.StringInterfaceToJson(post.Props)) <= model.POST_PROPS_MAX_USER_RUNES {
+		return
+	}
+	delete(post.Props, propOriginalMessage)
+
+	key := originalMessageKeyPrefix + model.NewId()
+	if appErr := p.API.KVSet(key, []byte(post.Message)); appErr != nil {
+		mlog.Error("Failed to store the original message: " + appErr.Error())
+		return
+	}
+	post.AddProp(propOriginalMessageKey, key)
+}
+
+// removePluginProps drops the props of the plugin a post was sent with. The props are read
+// back when reverting posts and sending webhook events, so only the plugin may set them.
+func removePluginProps(post *model.Post) {
+	for name := range post.Props {
+		if strings.HasPrefix(name, pluginPropPrefix) {
+			delete(post.Props, name)
+		}
+	}
+}
+
+// isOriginalMessageKey reports whether the key is one the plugin keeps original messages
+// in.
+func isOriginalMessageKey(key string) bool {
+	return strings.HasPrefix(key, originalMessageKeyPrefix) && model.IsValidId(strings.TrimPrefix(key, originalMessageKeyPrefix))
+}
+
+// originalMessage returns the message of a rewritten post as written by its author.
+func (p *Plugin) originalMessage(post *model.Post) (string, error) {
+	if original, ok := post.Props[propOriginalMessage].(string); ok {
+		return original, nil
+	}
+
+	key, ok := post.Props[propOriginalMessageKey].(string)
+	if !ok || !isOriginalMessageKey(key) {
+		return "", errPostNotRewritten
+	}
+	original, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return "", appErr
+	}
+	if original == nil {
+		return "", errPostNotRewritten
+	}
+	return string(original), nil
+}
+
+// postLinkNames returns the names of the links that changed the post.
+func postLinkNames(post *model.Post) []string {
+	switch names := post.Props[propLinks].(type) {
+	case []string:
+		return names
+	case []interface{}:
+		result := make([]string, 0, len(names))
+		for _, name := range names {
+			if s, ok := name.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// rewrittenEntry is a post to add to the index of a link, or, if written is set, a request to
+// be told when the entries queued before it are written.
+type rewrittenEntry struct {
+	linkName string
+	post     rewrittenPost
+	written  chan struct{}
+}
+
+// rewrittenIndexer adds rewritten posts to the index from a bounded queue, so that saving a
+// post does not wait for the KV store. The entries queued at once are written together, with
+// a single update of every part of the index they go to. Entries are dropped when the queue
+// is full.
+//
+// The index is split by link, day and node, and every part of it is only ever written by one
+// node, so that nodes do not overwrite the entries of the others. A part that is full is
+// continued in the next one.
+type rewrittenIndexer struct {
+	queue chan *rewrittenEntry
+	stop  chan struct{}
+	done  chan struct{}
+
+	// The fields below are only used by the goroutine writing the index. parts holds the
+	// part of the index written to for every link, on the current day.
+	day   string
+	parts map[string]int
+
+	// listedAt is when the node last checked that it is listed among the nodes that indexed
+	// posts, and since when the first post was indexed.
+	listedAt time.Time
+	since    int64
+}
+
+// startRewrittenIndexer starts writing the index of rewritten posts of this node.
+func (p *Plugin) startRewrittenIndexer(queueSize int) *rewrittenIndexer {
+	ix := &rewrittenIndexer{
+		queue: make(chan *rewrittenEntry, queueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		parts: make(map[string]int),
+	}
+	go func() {
+		defer close(ix.done)
+		for {
+			select {
+			case entry := <-ix.queue:
+				p.writeRewrittenPosts(ix, ix.drain(entry))
+			case <-ix.stop:
+				p.writeRewrittenPosts(ix, ix.drain(nil))
+				return
+			}
+		}
+	}()
+	return ix
+}
+
+// close stops writing the index, once the entries still queued are written.
+func (ix *rewrittenIndexer) close() {
+	close(ix.stop)
+	<-ix.done
+}
+
+// enqueue queues an entry without blocking and reports whether there was room for it.
+func (ix *rewrittenIndexer) enqueue(entry *rewrittenEntry) bool {
+	select {
+	case ix.queue <- entry:
+		return true
+	default:
+		return false
+	}
+}
+
+// flush waits until the entries queued so far are written.
+func (ix *rewrittenIndexer) flush() {
+	written := make(chan struct{})
+	select {
+	case ix.queue <- &rewrittenEntry{written: written}:
+		<-written
+	case <-ix.done:
+	}
+}
+
+// drain returns the entry along with the others queued after it.
+func (ix *rewrittenIndexer) drain(entry *rewrittenEntry) []*rewrittenEntry {
+	var batch []*rewrittenEntry
+	if entry != nil {
+		batch = append(batch, entry)
+	}
+	for {
+		select {
+		case entry := <-ix.queue:
+			batch = append(batch, entry)
+		default:
+			return batch
+		}
+	}
+}
+
+// rewrittenPostsKey returns the key of a part of the index. The key is hashed to keep
+// within the length limit of keys.
+func rewrittenPostsKey(linkName, day, nodeID string, part int) string {
+	sum := md5.Sum([]byte(linkName + "\x00" + day + "\x00" + nodeID + "\x00" + strconv.Itoa(part)))
+	return rewrittenPostsKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func rewrittenDay(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(rewrittenDayFormat)
+}
+
+// readRewrittenPosts returns the entries of a part of the index, or nil if the part does
+// not exist.
+func (p *Plugin) readRewrittenPosts(key string) ([]rewrittenPost, error) {
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var posts []rewrittenPost
+	if err := json.Unmarshal(data, &posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (p *Plugin) getRewrittenNodes() ([]string, error) {
+	data, appErr := p.API.KVGet(rewrittenNodesKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+	var nodes []string
+	if data == nil {
+		return nodes, nil
+	}
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// getRewrittenSince returns when the first post was indexed, or 0 if none was.
+func (p *Plugin) getRewrittenSince() (int64, error) {
+	data, appErr := p.API.KVGet(rewrittenSinceKey)
+	if appErr != nil {
+		return 0, appErr
+	}
+	if data == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// indexRewrittenPost queues a saved post for the index of every link that changed it. The
+// index is what allows reverting all posts changed by a link within a time range.
+func (p *Plugin) indexRewrittenPost(post *model.Post) {
+	if p.rewritten == nil {
+		return
+	}
+	for _, name := range postLinkNames(post) {
+		if !p.rewritten.enqueue(&rewrittenEntry{linkName: name, post: rewrittenPost{PostId: post.Id, CreateAt: post.CreateAt}}) {
+			mlog.Warn("Queue of the index of rewritten posts is full, the post cannot be reverted by link",
+				mlog.String("link", name),
+				mlog.String("post_id", post.Id))
+		}
+	}
+}
+
+// writeRewrittenPosts adds a batch of entries to the index, and then signals the requests to
+// be told when they are written.
+func (p *Plugin) writeRewrittenPosts(ix *rewrittenIndexer, batch []*rewrittenEntry) {
+	nodeID := p.getNodeReporter().id
+
+	type group struct{ linkName, day string }
+	var groups []group
+	entries := make(map[group][]rewrittenPost)
+	var createAt int64
+	for _, entry := range batch {
+		if entry.written != nil {
+			continue
+		}
+		g := group{linkName: entry.linkName, day: rewrittenDay(entry.post.CreateAt)}
+		if _, ok := entries[g]; !ok {
+			groups = append(groups, g)
+		}
+		entries[g] = append(entries[g], entry.post)
+		if createAt == 0 || entry.post.CreateAt < createAt {
+			createAt = entry.post.CreateAt
+		}
+	}
+
+	for _, g := range groups {
+		if g.day != ix.day {
+			ix.day, ix.parts = g.day, make(map[string]int)
+		}
+		if err := p.addRewrittenPosts(ix, g.linkName, g.day, nodeID, entries[g]); err != nil {
+			mlog.Error("Failed to index rewritten posts: "+err.Error(), mlog.String("link", g.linkName), mlog.Int("posts", len(entries[g])))
+		}
+	}
+
+	if len(groups) > 0 && time.Since(ix.listedAt) >= nodeReportInterval {
+		if err := p.listRewrittenNode(ix, nodeID, createAt); err != nil {
+			mlog.Error("Failed to list the node in the index of rewritten posts: " + err.Error())
+		} else {
+			ix.listedAt = time.Now()
+		}
+	}
+
+	for _, entry := range batch {
+		if entry.written != nil {
+			close(entry.written)
+		}
+	}
+}
+
+// addRewrittenPosts adds entries to the parts of the index of the link this node writes to.
+func (p *Plugin) addRewrittenPosts(ix *rewrittenIndexer, linkName, day, nodeID string, entries []rewrittenPost) error {
+	for part := ix.parts[linkName]; len(entries) > 0; part++ {
+		key := rewrittenPostsKey(linkName, day, nodeID, part)
+		posts, err := p.readRewrittenPosts(key)
+		if err != nil {
+			return err
+		}
+		n := maxRewrittenPostsPerKey - len(posts)
+		if n <= 0 {
+			continue
+		}
+		if n > len(entries) {
+			n = len(entries)
+		}
+
+		data, err := json.Marshal(append(posts, entries[:n]...))
+		if err != nil {
+			return err
+		}
+		if appErr := p.API.KVSet(key, data); appErr != nil {
+			return appErr
+		}
+		ix.parts[linkName] = part
+		entries = entries[n:]
+	}
+	return nil
+}
+
+// listRewrittenNode records that this node indexed posts, and when the index started. Without
+// atomic updates, two nodes listing themselves at once may drop one of them from the list.
+// Nodes check the list again at most every nodeReportInterval, adding themselves back, and
+// their entries are kept in their own parts of the index meanwhile.
+func (p *Plugin) listRewrittenNode(ix *rewrittenIndexer, nodeID string, createAt int64) error {
+	if ix.since == 0 {
+		since, err := p.getRewrittenSince()
+		if err != nil {
+			return err
+		}
+		if since == 0 {
+			since = createAt
+			if appErr := p.API.KVSet(rewrittenSinceKey, []byte(strconv.FormatInt(since, 10))); appErr != nil {
+				return appErr
+			}
+		}
+		ix.since = since
+	}
+
+	nodes, err := p.getRewrittenNodes()
+	if err != nil {
+		return err
+	}
+	for _, id := range nodes {
+		if id == nodeID {
+			return nil
+		}
+	}
+	data, err := json.Marshal(append(nodes, nodeID))
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(rewrittenNodesKey, data); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// findRewrittenPosts returns the posts changed by the link that were created within
+// [from, to), as indexed by every node. Posts created before the index started are not
+// found.
+func (p *Plugin) findRewrittenPosts(linkName string, from, to int64) ([]rewrittenPost, error) {
+	since, err := p.getRewrittenSince()
+	if err != nil || since == 0 {
+		return nil, err
+	}
+	nodes, err := p.getRewrittenNodes()
+	if err != nil {
+		return nil, err
+	}
+	if from < since {
+		from = since
+	}
+
+	var found []rewrittenPost
+	start, _ := time.Parse(rewrittenDayFormat, rewrittenDay(from))
+	for day := start; day.UnixNano()/int64(time.Millisecond) < to; day = day.AddDate(0, 0, 1) {
+		for _, nodeID := range nodes {
+			for part := 0; ; part++ {
+				posts, err := p.readRewrittenPosts(rewrittenPostsKey(linkName, day.Format(rewrittenDayFormat), nodeID, part))
+				if err != nil {
+					return nil, err
+				}
+				if posts == nil {
+					break
+				}
+				for _, post := range posts {
+					if post.CreateAt >= from && post.CreateAt < to {
+						found = append(found, post)
+					}
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// revertPost restores the original message of a rewritten post. Posts edited after they were
+// linked are left alone, since reverting them would discard the edit, and since edits may
+// change the props.
+//
+// An original message kept in the KV store is not deleted: the key is read from the post,
+// and the entry is small next to the post it belongs to.
+func (p *Plugin) revertPost(postID string) error {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		return appErr
+	}
+
+	original, err := p.originalMessage(post)
+	if err != nil {
+		return err
+	}
+	if post.EditAt != 0 {
+		return errPostEdited
+	}
+
+	post.Message = original
+	delete(post.Props, propOriginalMessage)
+	delete(post.Props, propOriginalMessageKey)
+	delete(post.Props, propLinks)
+	delete(post.Props, propEntities)
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// revertLink restores the original message of the posts changed by a link that were created
+// within [from, to). It returns the number of posts reverted and the errors for the others.
+func (p *Plugin) revertLink(linkName string, from, to time.Time) (int, map[string]error, error) {
+	// Posts saved on this node just before are found too.
+	if p.rewritten != nil {
+		p.rewritten.flush()
+	}
+	posts, err := p.findRewrittenPosts(linkName, from.UnixNano()/int64(time.Millisecond), to.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	reverted := 0
+	failed := make(map[string]error)
+	for _, post := range posts {
+		if err := p.revertPost(post.PostId); err == errPostNotRewritten {
+			// Already reverted, or reverted through another link.
+			continue
+		} else if err != nil {
+			failed[post.PostId] = err
+			continue
+		}
+		reverted++
+	}
+
+	return reverted, failed, nil
+}
+
+// executeRevertCommand handles
+//
+//	/autolink revert post <post-id>
+//	/autolink revert link <name> <from> [<to>]
+func (p *Plugin) executeRevertCommand(T i18n.TranslateFunc, args []string) string {
+	if len(args) == 2 && args[0] == "post" {
+		if err := p.revertPost(args[1]); err != nil {
+			return T("autolink.command.revert.post_failed", map[string]interface{}{"PostId": args[1], "Error": translateError(T, err)})
+		}
+		return T("autolink.command.revert.post_done", map[string]interface{}{"PostId": args[1]})
+	}
+
+	if (len(args) == 3 || len(args) == 4) && args[0] == "link" {
+		from, err := parseCommandTime(args[2])
+		if err != nil {
+			return T("autolink.command.revert.invalid_time", map[string]interface{}{"Error": translateError(T, err), "Usage": T("autolink.command.revert.usage")})
+		}
+		to := time.Now()
+		if len(args) == 4 {
+			if to, err = parseCommandTime(args[3]); err != nil {
+				return T("autolink.command.revert.invalid_time", map[string]interface{}{"Error": translateError(T, err), "Usage": T("autolink.command.revert.usage")})
+			}
+		}
+
+		reverted, failed, err := p.revertLink(args[1], from, to)
+		if err != nil {
+			return T("autolink.command.revert.link_failed", map[string]interface{}{"Link": args[1], "Error": translateError(T, err)})
+		}
+
+		text := T("autolink.command.revert.link_done", map[string]interface{}{"Posts": reverted, "Link": args[1]})
+		if since, err := p.getRewrittenSince(); err != nil {
+			mlog.Warn("Failed to load when the index of rewritten posts started: " + err.Error())
+		} else if since == 0 {
+			text += " " + T("autolink.command.revert.not_indexed")
+		} else if since > from.UnixNano()/int64(time.Millisecond) {
+			text += " " + T("autolink.command.revert.indexed_since", map[string]interface{}{"Since": formatMillis(since)})
+		}
+		for postID, err := range failed {
+			text += "\n* " + T("autolink.command.revert.post_failed", map[string]interface{}{"PostId": postID, "Error": translateError(T, err)})
+		}
+		return text
+	}
+
+	return T("autolink.command.revert.usage")
+}
+
+// parseCommandTime parses a date or a RFC 3339 timestamp given to a command.
+func parseCommandTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, newLocalizedError("autolink.command.invalid_time", map[string]interface{}{"Value": value})
+}
@@ -0,0 +1,268 @@
+package autolink
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRevertTest(t *testing.T) (*Plugin, map[string]*model.Post, map[string][]byte) {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}, {
+			Name:     "jira",
+			Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+			Template: "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+		}},
+	}
+
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Locale: "en"}, nil)
+	api.On("HasPermissionTo", "user", model.PERMISSION_MANAGE_SYSTEM).Return(false)
+	store := mockKVStore(api)
+	posts := mockPostStore(api)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+	p.rewritten = p.startRewrittenIndexer(maxQueuedRewrittenPosts)
+
+	return p, posts, store
+}
+
+// createPost runs a message through the posting hooks and stores the result.
+func createPost(p *Plugin, posts map[string]*model.Post, message string, createAt time.Time) *model.Post {
+	post := &model.Post{Message: message}
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, post)
+	post.Id = model.NewId()
+	post.CreateAt = createAt.UnixNano() / int64(time.Millisecond)
+	stored := *post
+	stored.Props = model.StringInterface{}
+	for k, v := range post.Props {
+		stored.Props[k] = v
+	}
+	posts[post.Id] = &stored
+	p.MessageHasBeenPosted(&plugin.Context{}, post)
+	if p.rewritten != nil {
+		p.rewritten.flush()
+	}
+	return post
+}
+
+func TestKeepOriginalMessage(t *testing.T) {
+	p, _, store := setupRevertTest(t)
+
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "Welcome to Mattermost, see MM-123"})
+	assert.Equal(t, "Welcome to [Mattermost](https://mattermost.com), see [MM-123](https://mattermost.atlassian.net/browse/MM-123)", post.Message)
+	assert.Equal(t, "Welcome to Mattermost, see MM-123", post.Props[propOriginalMessage])
+	assert.Equal(t, []string{"mattermost", "jira"}, post.Props[propLinks])
+
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: "Nothing to link"})
+	assert.Nil(t, post.Props)
+
+	message := strings.Repeat("word ", 2000) + "Mattermost"
+	post, _ = p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: message})
+	assert.Nil(t, post.Props[propOriginalMessage])
+	key, ok := post.Props[propOriginalMessageKey].(string)
+	require.True(t, ok)
+	assert.Equal(t, message, string(store[key]))
+}
+
+func TestRevertPost(t *testing.T) {
+	p, posts, store := setupRevertTest(t)
+
+	post := createPost(p, posts, "Welcome to Mattermost", time.Now())
+	require.Equal(t, "Welcome to [Mattermost](https://mattermost.com)", posts[post.Id].Message)
+	require.NotNil(t, posts[post.Id].Props[propEntities])
+
+	require.Nil(t, p.revertPost(post.Id))
+	assert.Equal(t, "Welcome to Mattermost", posts[post.Id].Message)
+	assert.Nil(t, posts[post.Id].Props[propOriginalMessage])
+	assert.Nil(t, posts[post.Id].Props[propLinks])
+	assert.Nil(t, posts[post.Id].Props[propEntities])
+	assert.Equal(t, errPostNotRewritten, p.revertPost(post.Id))
+
+	post = createPost(p, posts, strings.Repeat("word ", 2000)+"Mattermost", time.Now())
+	key := posts[post.Id].Props[propOriginalMessageKey].(string)
+	require.Nil(t, p.revertPost(post.Id))
+	assert.Equal(t, strings.Repeat("word ", 2000)+"Mattermost", posts[post.Id].Message)
+	assert.NotNil(t, store[key])
+
+	post = createPost(p, posts, "Welcome to Mattermost", time.Now())
+	posts[post.Id].EditAt = model.GetMillis()
+	assert.Equal(t, errPostEdited, p.revertPost(post.Id))
+	assert.Equal(t, "Welcome to [Mattermost](https://mattermost.com)", posts[post.Id].Message)
+
+	post = createPost(p, posts, "Nothing to link", time.Now())
+	assert.Equal(t, errPostNotRewritten, p.revertPost(post.Id))
+}
+
+func TestRevertForgedProps(t *testing.T) {
+	p, posts, store := setupRevertTest(t)
+	store[linkHeadKey] = []byte("1")
+	store[linkRevisionKey(1)] = []byte(`{"Revision":1}`)
+
+	// Props of the plugin sent with a post are dropped, whether or not the post is linked.
+	for _, message := range []string{"Nothing to link", "Welcome to Mattermost"} {
+		post := &model.Post{Message: message}
+		post.AddProp(propOriginalMessageKey, linkRevisionKey(1))
+		post.AddProp(propEntities, []interface{}{map[string]interface{}{"Link": "jira"}})
+		post.AddProp("autolink_other", true)
+		post.AddProp("other", true)
+		post, _ = p.MessageWillBePosted(&plugin.Context{}, post)
+
+		assert.Nil(t, post.Props[propOriginalMessageKey])
+		assert.Nil(t, post.Props["autolink_other"])
+		assert.Equal(t, true, post.Props["other"])
+		assert.NotEqual(t, []interface{}{map[string]interface{}{"Link": "jira"}}, post.Props[propEntities])
+	}
+
+	// Keys the plugin did not create are not read, and keys are never deleted.
+	post := &model.Post{Id: model.NewId(), Message: "Welcome"}
+	post.AddProp(propOriginalMessageKey, linkRevisionKey(1))
+	post.AddProp(propLinks, []string{"mattermost"})
+	posts[post.Id] = post
+	assert.Equal(t, errPostNotRewritten, p.revertPost(post.Id))
+	assert.Equal(t, "Welcome", posts[post.Id].Message)
+	assert.NotNil(t, store[linkRevisionKey(1)])
+}
+
+func TestRewrittenPostsIndex(t *testing.T) {
+	p, posts, store := setupRevertTest(t)
+	p.nodeOnce.Do(func() {
+		p.node = newNodeReporter(p.API, "a")
+	})
+	other := &Plugin{}
+	other.SetAPI(p.API)
+	require.Nil(t, other.OnConfigurationChange())
+	other.rewritten = other.startRewrittenIndexer(maxQueuedRewrittenPosts)
+	other.nodeOnce.Do(func() {
+		other.node = newNodeReporter(p.API, "b")
+	})
+
+	day := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	first := createPost(p, posts, "MM-1", day)
+	second := createPost(other, posts, "MM-2", day)
+
+	// Every node writes its own part of the index, and a full part is continued in the next.
+	full := make([]rewrittenPost, maxRewrittenPostsPerKey)
+	data, err := json.Marshal(full)
+	require.Nil(t, err)
+	store[rewrittenPostsKey("jira", "2018-10-02", "a", 0)] = data
+	third := createPost(p, posts, "MM-3", day.AddDate(0, 0, 1))
+	assert.NotNil(t, store[rewrittenPostsKey("jira", "2018-10-02", "a", 1)])
+
+	found, err := p.findRewrittenPosts("jira", 0, model.GetMillis())
+	require.Nil(t, err)
+	var ids []string
+	for _, post := range found {
+		if post.PostId != "" {
+			ids = append(ids, post.PostId)
+		}
+	}
+	assert.ElementsMatch(t, []string{first.Id, second.Id, third.Id}, ids)
+
+	found, err = p.findRewrittenPosts("jira", day.AddDate(0, 0, 1).UnixNano()/int64(time.Millisecond), model.GetMillis())
+	require.Nil(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, third.Id, found[0].PostId)
+}
+
+func TestRewrittenIndexerBatch(t *testing.T) {
+	p, _, store := setupRevertTest(t)
+	p.nodeOnce.Do(func() {
+		p.node = newNodeReporter(p.API, "a")
+	})
+
+	// A batch fills the part it starts in and continues in the next.
+	almostFull := make([]rewrittenPost, maxRewrittenPostsPerKey-1)
+	data, err := json.Marshal(almostFull)
+	require.Nil(t, err)
+	store[rewrittenPostsKey("jira", "2018-10-01", "a", 0)] = data
+
+	createAt := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	written := make(chan struct{})
+	p.writeRewrittenPosts(p.rewritten, []*rewrittenEntry{
+		{linkName: "jira", post: rewrittenPost{PostId: "1", CreateAt: createAt}},
+		{linkName: "jira", post: rewrittenPost{PostId: "2", CreateAt: createAt}},
+		{written: written},
+		{linkName: "jira", post: rewrittenPost{PostId: "3", CreateAt: createAt}},
+	})
+	<-written
+
+	first, err := p.readRewrittenPosts(rewrittenPostsKey("jira", "2018-10-01", "a", 0))
+	require.Nil(t, err)
+	assert.Len(t, first, maxRewrittenPostsPerKey)
+	assert.Equal(t, "1", first[len(first)-1].PostId)
+	second, err := p.readRewrittenPosts(rewrittenPostsKey("jira", "2018-10-01", "a", 1))
+	require.Nil(t, err)
+	assert.Equal(t, []rewrittenPost{{PostId: "2", CreateAt: createAt}, {PostId: "3", CreateAt: createAt}}, second)
+
+	// Entries still queued are written when the indexer stops.
+	p.indexRewrittenPost(&model.Post{Id: "4", CreateAt: createAt, Props: model.StringInterface{propLinks: []string{"jira"}}})
+	p.rewritten.close()
+	second, err = p.readRewrittenPosts(rewrittenPostsKey("jira", "2018-10-01", "a", 1))
+	require.Nil(t, err)
+	assert.Len(t, second, 3)
+
+	// Posts are not indexed once the queue is full, rather than holding up posting.
+	ix := &rewrittenIndexer{queue: make(chan *rewrittenEntry, 1)}
+	assert.True(t, ix.enqueue(&rewrittenEntry{linkName: "jira"}))
+	assert.False(t, ix.enqueue(&rewrittenEntry{linkName: "jira"}))
+}
+
+func TestRevertCommandNotIndexed(t *testing.T) {
+	p, _, _ := setupRevertTest(t)
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert link jira 2018-10-02"})
+	assert.Equal(t, "Reverted 0 posts changed by jira. No linked posts were indexed yet.", resp.Text)
+}
+
+func TestRevertCommand(t *testing.T) {
+	p, posts, _ := setupRevertTest(t)
+
+	old := createPost(p, posts, "MM-1 and Mattermost", time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC))
+	recent := createPost(p, posts, "MM-2", time.Date(2018, 10, 3, 12, 0, 0, 0, time.UTC))
+	other := createPost(p, posts, "Mattermost", time.Date(2018, 10, 3, 12, 0, 0, 0, time.UTC))
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "user", Command: "/autolink revert link jira 2018-10-02"})
+	assert.Equal(t, "Only system administrators can use `/autolink`.", resp.Text)
+	assert.Equal(t, "[MM-2](https://mattermost.atlassian.net/browse/MM-2)", posts[recent.Id].Message)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert link jira 2018-10-02"})
+	assert.Equal(t, "Reverted 1 posts changed by jira.", resp.Text)
+	assert.Equal(t, "MM-2", posts[recent.Id].Message)
+	assert.Equal(t, "[MM-1](https://mattermost.atlassian.net/browse/MM-1) and [Mattermost](https://mattermost.com)", posts[old.Id].Message)
+	assert.Equal(t, "[Mattermost](https://mattermost.com)", posts[other.Id].Message)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert link jira 2018-09-30T00:00:00Z 2018-10-02T00:00:00Z"})
+	assert.Equal(t, "Reverted 1 posts changed by jira. Posts created before 2018-10-01 12:00 UTC are not in the index of linked posts, and were not reverted.", resp.Text)
+	assert.Equal(t, "MM-1 and Mattermost", posts[old.Id].Message)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert post " + other.Id})
+	assert.Equal(t, "Reverted post "+other.Id+".", resp.Text)
+	assert.Equal(t, "Mattermost", posts[other.Id].Message)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert link jira yesterday"})
+	assert.Contains(t, resp.Text, `invalid time "yesterday"`)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink revert"})
+	assert.Equal(t, "Usage: `/autolink revert post <post-id>` or `/autolink revert link <name> <from> [<to>]`. "+
+		"Times are given as `2006-01-02` or `2006-01-02T15:04:05Z07:00`.", resp.Text)
+}
@@ -0,0 +1,100 @@
+package autolink
+
+import (
+	"regexp"
+	"strings"
+)
+
+// simpleClasses are the kinds of text a placeholder of a simple pattern can match.
+var simpleClasses = map[string]string{
+	"digits":  `\d+`,
+	"letters": `\pL+`,
+	"word":    `[\pL\pN_]+`,
+	"text":    `\S+`,
+}
+
+var simpleGroupNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// compileSimplePattern converts a simple pattern to a regular expression. Text is matched
+// literally, except for
+//
+//	{name}        a word, captured as name
+//	{name:class}  digits, letters, word or text, captured as name
+//	*             any characters but whitespace, not ending with punctuation
+//	\             escaping the next character, as in \* or \{
+func compileSimplePattern(pattern string) (string, error) {
+	var b strings.Builder
+	names := make(map[string]bool)
+
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '\\':
+			if i+1 >= len(pattern) {
+				return "", newLocalizedError("autolink.link.simple_escape", nil)
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 2
+
+		case '*':
+			// Leave trailing punctuation out, so that it can end the match.
+			b.WriteString(`(?:\S*[^\s.!?,)])?`)
+			i++
+
+		case '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				return "", newLocalizedError("autolink.link.simple_unclosed", map[string]interface{}{"Position": i})
+			}
+			placeholder := pattern[i+1 : i+end]
+			i += end + 1
+
+			name, class := placeholder, "word"
+			if colon := strings.IndexByte(placeholder, ':'); colon >= 0 {
+				name, class = placeholder[:colon], placeholder[colon+1:]
+			}
+			if !simpleGroupNamePattern.MatchString(name) {
+				return "", newLocalizedError("autolink.link.simple_name", map[string]interface{}{"Name": name})
+			}
+			if names[name] {
+				return "", newLocalizedError("autolink.link.simple_duplicate", map[string]interface{}{"Name": name})
+			}
+			names[name] = true
+
+			expression, ok := simpleClasses[class]
+			if !ok {
+				return "", newLocalizedError("autolink.link.simple_class", map[string]interface{}{"Class": class})
+			}
+			b.WriteString("(?P<" + name + ">" + expression + ")")
+
+		case '}':
+			return "", newLocalizedError("autolink.link.simple_unexpected_brace", map[string]interface{}{"Position": i})
+
+		default:
+			// Copy the text up to the next special character literally.
+			end := strings.IndexAny(pattern[i:], `\*{}`)
+			if end < 0 {
+				end = len(pattern) - i
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+end]))
+			i += end
+		}
+	}
+
+	return b.String(), nil
+}
+
+// newSimpleMatcher matches the regular expression compiled from the simple pattern of the link.
+func newSimpleMatcher(link *Link, conf *Configuration) (Matcher, error) {
+	if len(link.Pattern) == 0 {
+		return nil, errPatternOrTemplateEmpty
+	}
+
+	expression, err := compileSimplePattern(link.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := *link
+	compiled.Pattern = expression
+	return newRegexpMatcher(&compiled, conf)
+}
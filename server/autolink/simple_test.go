@@ -0,0 +1,64 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileSimplePattern(t *testing.T) {
+	for _, tt := range []struct {
+		Pattern  string
+		Expected string
+		Error    string
+	}{
+		{Pattern: "MM-{id:digits}", Expected: `MM-(?P<id>\d+)`},
+		{Pattern: "{user}@example.com", Expected: `(?P<user>[\pL\pN_]+)@example\.com`},
+		{Pattern: "https://example.com/*", Expected: `https://example\.com/(?:\S*[^\s.!?,)])?`},
+		{Pattern: `a\*b\{c\}`, Expected: `a\*b\{c\}`},
+		{Pattern: "{team:letters}/{path:text}", Expected: `(?P<team>\pL+)/(?P<path>\S+)`},
+		{Pattern: "MM-{id", Error: "placeholder at 3 is not closed"},
+		{Pattern: "MM-}", Error: "unexpected } at 3"},
+		{Pattern: "{1d}", Error: `invalid placeholder name "1d"`},
+		{Pattern: "{id:hex}", Error: `unknown placeholder class "hex", expected digits, letters, word or text`},
+		{Pattern: "{id}-{id}", Error: `placeholder "id" is used twice`},
+		{Pattern: `MM\`, Error: "pattern ends with an unfinished escape"},
+	} {
+		expression, err := compileSimplePattern(tt.Pattern)
+		if tt.Error != "" {
+			assert.EqualError(t, err, tt.Error, tt.Pattern)
+		} else if assert.Nil(t, err, tt.Pattern) {
+			assert.Equal(t, tt.Expected, expression, tt.Pattern)
+		}
+	}
+}
+
+func TestSimpleLink(t *testing.T) {
+	al, err := NewAutoLinker(&Link{
+		Type:     linkTypeSimple,
+		Pattern:  "MM-{id:digits}",
+		Template: "[MM-${id}](https://mattermost.atlassian.net/browse/MM-${id})",
+	}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "See [MM-123](https://mattermost.atlassian.net/browse/MM-123). Not MM-abc or XMM-1.", al.Replace("See MM-123. Not MM-abc or XMM-1."))
+
+	al, err = NewAutoLinker(&Link{
+		Type:     linkTypeSimple,
+		Pattern:  "go/{link:text}",
+		Template: "[go/${link}](https://golinks.example.com/${link})",
+	}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "visit [go/wiki](https://golinks.example.com/wiki)", al.Replace("visit go/wiki"))
+}
+
+func TestSimpleLinkWildcard(t *testing.T) {
+	al, err := NewAutoLinker(&Link{
+		Type:     linkTypeSimple,
+		Pattern:  "https://pre-release.mattermost.com/*",
+		Template: "[<pre-release link>](https://pre-release.mattermost.com)",
+	}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "see [<pre-release link>](https://pre-release.mattermost.com).", al.Replace("see https://pre-release.mattermost.com/core/pl/abc."))
+	assert.Equal(t, "https://pre-release.mattermostXcom/", al.Replace("https://pre-release.mattermostXcom/"))
+}
@@ -0,0 +1,316 @@
+package autolink
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/nicksnyder/go-i18n/i18n"
+)
+
+// Storage modes of the links.
+const (
+	// storageConfig keeps the links in the plugin settings of config.json.
+	storageConfig = "config"
+
+	// storageKV keeps the links in the KV store as numbered revisions, so that changes can
+	// be reviewed and rolled back.
+	storageKV = "kv"
+)
+
+const (
+	linkRevisionKeyPrefix = "links_revision_"
+	linkHeadKey           = "links_head"
+
+	// maxLinkRevisions bounds the number of revisions kept. Older revisions are deleted.
+	maxLinkRevisions = 100
+
+	// historyLength is the number of revisions listed by /autolink history.
+	historyLength = 20
+)
+
+// Changes recorded in link revisions.
+const (
+	changeImport   = "import"
+	changeCreate   = "create"
+	changeUpdate   = "update"
+	changeDelete   = "delete"
+	changeRollback = "rollback"
+)
+
+// linkRevision is a version of the links kept in the KV store. The latest revision is the
+// active one.
+type linkRevision struct {
+	Revision int
+
+	// Version is the version of the configuration schema the links were saved in.
+	Version int
+	Links   []*Link
+
+	UserId   string
+	CreateAt int64
+
+	// Stamp is unique to every revision saved, see linkHead.
+	Stamp string `json:",omitempty"`
+
+	// Change tells how the revision came about: the link created, updated or deleted, or the
+	// revision rolled back to.
+	Change string
+	Link   string `json:",omitempty"`
+	From   int    `json:",omitempty"`
+}
+
+func linkRevisionKey(revision int) string {
+	return linkRevisionKeyPrefix + strconv.Itoa(revision)
+}
+
+// linkHead points to the latest revision. Without atomic updates of the KV store, two nodes
+// may save a revision with the same number at the same time, and the stamp of the revision
+// tells them apart.
+type linkHead struct {
+	Revision int
+	Stamp    string
+}
+
+var errLinksChangedConcurrently = newLocalizedError("autolink.storage.concurrent_change", nil)
+
+// getLinkHead returns the head of the revisions, with revision 0 if none was saved.
+func (p *Plugin) getLinkHead() (*linkHead, error) {
+	data, appErr := p.API.KVGet(linkHeadKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return &linkHead{}, nil
+	}
+
+	// Heads saved before revisions were stamped hold the number of the revision alone.
+	if revision, err := strconv.Atoi(string(data)); err == nil {
+		return &linkHead{Revision: revision}, nil
+	}
+	var head linkHead
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+func (p *Plugin) setLinkHead(head *linkHead) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(linkHeadKey, data); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// getLinkRevision returns a revision of the links, migrated to the current configuration
+// schema.
+func (p *Plugin) getLinkRevision(revision int) (*linkRevision, error) {
+	data, appErr := p.API.KVGet(linkRevisionKey(revision))
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return nil, newLocalizedError("autolink.storage.revision_not_found", map[string]interface{}{"Revision": revision})
+	}
+
+	var rev linkRevision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, err
+	}
+
+	c := &Configuration{Version: rev.Version, Links: rev.Links}
+	if _, err := migrateConfiguration(c); err != nil {
+		return nil, err
+	}
+	rev.Version, rev.Links = c.Version, c.Links
+	return &rev, nil
+}
+
+// storeLinkRevision saves the links as a new revision, which becomes the active one. The
+// revision describes the change; its number, stamp, links and time are filled in. It returns
+// errLinksChangedConcurrently if another node saved a revision with the same number at the
+// same time, and its revision was kept.
+func (p *Plugin) storeLinkRevision(links []*Link, rev *linkRevision) error {
+	head, err := p.getLinkHead()
+	if err != nil {
+		return err
+	}
+
+	rev.Revision = head.Revision + 1
+	rev.Stamp = model.NewId()
+	rev.Version = currentConfigurationVersion()
+	rev.Links = links
+	rev.CreateAt = model.GetMillis()
+
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.KVSet(linkRevisionKey(rev.Revision), data); appErr != nil {
+		return appErr
+	}
+	if err := p.setLinkHead(&linkHead{Revision: rev.Revision, Stamp: rev.Stamp}); err != nil {
+		return err
+	}
+	if err := p.checkLinkRevisionKept(rev); err != nil {
+		return err
+	}
+
+	if old := rev.Revision - maxLinkRevisions; old > 0 {
+		if appErr := p.API.KVDelete(linkRevisionKey(old)); appErr != nil {
+			mlog.Warn("Failed to delete an old revision of the links: "+appErr.Error(), mlog.Int("revision", old))
+		}
+	}
+	return nil
+}
+
+// checkLinkRevisionKept reads back the revision just saved and the head, to find out whether
+// another node saved a revision with the same number over it.
+func (p *Plugin) checkLinkRevisionKept(rev *linkRevision) error {
+	saved, err := p.getLinkRevision(rev.Revision)
+	if err != nil {
+		return err
+	}
+	head, err := p.getLinkHead()
+	if err != nil {
+		return err
+	}
+	if saved.Stamp == rev.Stamp && head.Revision == rev.Revision && head.Stamp == rev.Stamp {
+		return nil
+	}
+
+	// If this node saved the head last, it points to the revision of the other node, which
+	// was kept, so that the head and the revision agree.
+	if saved.Stamp != rev.Stamp && head.Revision == rev.Revision && head.Stamp == rev.Stamp {
+		if err := p.setLinkHead(&linkHead{Revision: saved.Revision, Stamp: saved.Stamp}); err != nil {
+			return err
+		}
+	}
+	mlog.Warn("The links were changed on another node at the same time, this change was not kept", mlog.Int("revision", rev.Revision))
+	return errLinksChangedConcurrently
+}
+
+// activeLinkRevision returns the active revision of the links. When the KV store holds no
+// revision yet, the links of the configuration are imported as the first one.
+func (p *Plugin) activeLinkRevision(c *Configuration) (*linkRevision, error) {
+	head, err := p.getLinkHead()
+	if err != nil {
+		return nil, err
+	}
+	if head.Revision > 0 {
+		return p.getLinkRevision(head.Revision)
+	}
+
+	rev := &linkRevision{Change: changeImport}
+	if err := p.storeLinkRevision(c.Links, rev); err == errLinksChangedConcurrently {
+		// Another node imported its links first.
+		if head, err = p.getLinkHead(); err != nil {
+			return nil, err
+		}
+		return p.getLinkRevision(head.Revision)
+	} else if err != nil {
+		return nil, err
+	}
+	mlog.Info("Imported the links of the configuration into the KV store", mlog.Int("links", len(c.Links)))
+	return rev, nil
+}
+
+// executeHistoryCommand handles
+//
+//	/autolink history
+func (p *Plugin) executeHistoryCommand(T i18n.TranslateFunc) string {
+	if p.getConfiguration().Storage != storageKV {
+		return T("autolink.command.history.not_kv")
+	}
+
+	latest, err := p.getLinkHead()
+	if err != nil {
+		return T("autolink.command.history.failed", map[string]interface{}{"Error": translateError(T, err)})
+	}
+	head := latest.Revision
+	if head == 0 {
+		return T("autolink.command.history.empty")
+	}
+
+	text := T("autolink.command.history.header")
+	for revision := head; revision > 0 && revision > head-historyLength; revision-- {
+		rev, err := p.getLinkRevision(revision)
+		if err != nil {
+			// Deleted as one of the oldest revisions.
+			break
+		}
+
+		active := ""
+		if revision == head {
+			active = T("autolink.command.history.active")
+		}
+		text += "\n" + T("autolink.command.history.revision", map[string]interface{}{
+			"Revision": rev.Revision,
+			"Active":   active,
+			"Time":     formatMillis(rev.CreateAt),
+			"User":     p.revisionAuthor(T, rev),
+			"Change":   T("autolink.history."+rev.Change, map[string]interface{}{"Link": rev.Link, "From": rev.From}),
+			"Links":    len(rev.Links),
+		})
+	}
+	return text
+}
+
+// revisionAuthor names the user who saved a revision.
+func (p *Plugin) revisionAuthor(T i18n.TranslateFunc, rev *linkRevision) string {
+	if rev.UserId == "" {
+		return T("autolink.command.history.system")
+	}
+	if user, appErr := p.API.GetUser(rev.UserId); appErr == nil && user.Username != "" {
+		return "@" + user.Username
+	}
+	return rev.UserId
+}
+
+// executeRollbackCommand handles
+//
+//	/autolink rollback <revision>
+//
+// The links of the revision are saved as a new revision, so that the rollback itself shows in
+// the history and can be undone.
+func (p *Plugin) executeRollbackCommand(T i18n.TranslateFunc, userID string, args []string) string {
+	if p.getConfiguration().Storage != storageKV {
+		return T("autolink.command.history.not_kv")
+	}
+	if len(args) != 1 {
+		return T("autolink.command.rollback.usage")
+	}
+	revision, err := strconv.Atoi(args[0])
+	if err != nil || revision <= 0 {
+		return T("autolink.command.rollback.usage")
+	}
+
+	p.configurationLock.Lock()
+	defer p.configurationLock.Unlock()
+
+	if err := p.syncLinks(); err != nil {
+		return T("autolink.command.rollback.failed", map[string]interface{}{"Revision": revision, "Error": translateError(T, err)})
+	}
+
+	rev, err := p.getLinkRevision(revision)
+	if err != nil {
+		return T("autolink.command.rollback.failed", map[string]interface{}{"Revision": revision, "Error": translateError(T, err)})
+	}
+
+	change := &linkRevision{UserId: userID, Change: changeRollback, From: revision}
+	if err := p.saveLinks(rev.Links, change); err != nil {
+		return T("autolink.command.rollback.failed", map[string]interface{}{"Revision": revision, "Error": translateError(T, err)})
+	}
+	return T("autolink.command.rollback.done", map[string]interface{}{"From": revision, "Revision": change.Revision})
+}
+
+// formatMillis formats a time given in milliseconds for command replies.
+func formatMillis(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+}
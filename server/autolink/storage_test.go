@@ -0,0 +1,148 @@
+package autolink
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/nicksnyder/go-i18n/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupStorageTest(t *testing.T, conf *Configuration) (*Plugin, map[string][]byte) {
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = *conf
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", "admin").Return(&model.User{Username: "admin", Locale: "en"}, nil)
+	store := mockKVStore(api)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+
+	return p, store
+}
+
+func linkedMessage(p *Plugin, message string) string {
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{Message: message})
+	return post.Message
+}
+
+func TestKVStorage(t *testing.T) {
+	conf := &Configuration{
+		Storage: storageKV,
+		Links: []*Link{{
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}},
+	}
+	p, _ := setupStorageTest(t, conf)
+
+	// The links of the configuration are imported as the first revision.
+	assert.Equal(t, 1, linkHeadRevision(t, p))
+	assert.Equal(t, "[Mattermost](https://mattermost.com)", linkedMessage(p, "Mattermost"))
+
+	w := doAPIRequest(p, "admin", http.MethodPost, "/api/v1/links", `{"Name": "jira", "Pattern": "(MM)(-)(?P<jira_id>\\d+)", "Template": "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doAPIRequest(p, "admin", http.MethodDelete, "/api/v1/links/mattermost", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	// Changes apply right away, without the server configuration being saved.
+	assert.Equal(t, 3, linkHeadRevision(t, p))
+	assert.Equal(t, "Mattermost [MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(p, "Mattermost MM-1"))
+
+	// Links in the configuration are ignored once the KV store holds revisions.
+	conf.Links = nil
+	require.Nil(t, p.OnConfigurationChange())
+	assert.Equal(t, "Mattermost [MM-1](https://mattermost.atlassian.net/browse/MM-1)", linkedMessage(p, "Mattermost MM-1"))
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink history"})
+	assert.Regexp(t, "^Revisions of the links, newest first:\n"+
+		`\* \*\*3\*\* \(active\) \d{4}-\d\d-\d\d \d\d:\d\d UTC by @admin: deleted mattermost, 1 links\n`+
+		`\* \*\*2\*\* \d{4}-\d\d-\d\d \d\d:\d\d UTC by @admin: created jira, 2 links\n`+
+		`\* \*\*1\*\* \d{4}-\d\d-\d\d \d\d:\d\d UTC by the plugin: imported from the configuration, 1 links$`, resp.Text)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink rollback 1"})
+	assert.Equal(t, "Rolled back to revision 1, the links are now at revision 4.", resp.Text)
+	assert.Equal(t, "[Mattermost](https://mattermost.com) MM-1", linkedMessage(p, "Mattermost MM-1"))
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink history"})
+	assert.Contains(t, resp.Text, "* **4** (active)")
+	assert.Contains(t, resp.Text, "by @admin: rolled back to revision 1, 1 links")
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink rollback 9"})
+	assert.Equal(t, "Could not roll back to revision 9: revision 9 was not found.", resp.Text)
+
+	resp, _ = p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink rollback latest"})
+	assert.Equal(t, "Usage: `/autolink rollback <revision>`. `/autolink history` lists the revisions.", resp.Text)
+}
+
+func TestKVStoragePrunesRevisions(t *testing.T) {
+	p, store := setupStorageTest(t, &Configuration{Storage: storageKV})
+
+	for i := 0; i < maxLinkRevisions+5; i++ {
+		require.Nil(t, p.saveLinks(nil, &linkRevision{UserId: "admin", Change: changeUpdate, Link: "jira"}))
+	}
+
+	assert.Equal(t, 106, linkHeadRevision(t, p))
+	assert.Nil(t, store[linkRevisionKey(6)])
+	assert.NotNil(t, store[linkRevisionKey(7)])
+
+	resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: "/autolink rollback 6"})
+	assert.Equal(t, "Could not roll back to revision 6: revision 6 was not found.", resp.Text)
+}
+
+func TestConfigStorage(t *testing.T) {
+	// No KV store is involved: every call to it would fail the test.
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = Configuration{Links: []*Link{{
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}}}
+		return nil
+	})
+	api.On("HasPermissionTo", "admin", model.PERMISSION_MANAGE_SYSTEM).Return(true)
+	api.On("GetUser", "admin").Return(&model.User{Username: "admin", Locale: "en"}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	require.Nil(t, p.OnConfigurationChange())
+	assert.Equal(t, "[Mattermost](https://mattermost.com)", linkedMessage(p, "Mattermost"))
+
+	for _, command := range []string{"/autolink history", "/autolink rollback 1"} {
+		resp, _ := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: "admin", Command: command})
+		assert.Equal(t, "Link history is only kept when `Storage` is set to `kv` in the plugin configuration.", resp.Text)
+	}
+}
+
+func TestUnknownStorage(t *testing.T) {
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = Configuration{Storage: "database"}
+		return nil
+	})
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	err := p.OnConfigurationChange()
+	assert.EqualError(t, err, `unknown storage "database", expected config or kv`)
+	de := i18n.TranslateFunc(translations.MustTfunc("de"))
+	assert.Equal(t, `unbekannter Speicher "database", erwartet wird config oder kv`, translateError(de, err))
+}
+
+// linkHeadRevision returns the number of the latest revision of the links.
+func linkHeadRevision(t *testing.T, p *Plugin) int {
+	head, err := p.getLinkHead()
+	require.Nil(t, err)
+	return head.Revision
+}
@@ -0,0 +1,366 @@
+package autolink
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+// Reasons for leaving a node of a traced post alone.
+const (
+	traceSkippedCode     = "code"
+	traceSkippedLink     = "link"
+	traceSkippedMismatch = "mismatch"
+)
+
+// Outcomes of a traced post.
+const (
+	traceLinked    = "linked"
+	traceUnchanged = "unchanged"
+	traceExhausted = "budget_exhausted"
+	traceTooLong   = "too_long"
+)
+
+// maxTraceBoundaryMisses bounds the matches reported for a link that only matches without
+// its boundaries.
+const maxTraceBoundaryMisses = 3
+
+// traceSample returns a random number in [0, 1), to decide whether a post is traced.
+var traceSample = rand.Float64
+
+// TraceSettings turns on traces explaining how every post is linked, logged at the info
+// level. Texts of the message are redacted in the traces unless IncludeText is set.
+type TraceSettings struct {
+	Enabled bool
+
+	// UserId and ChannelId limit the traces to the posts of a user, or in a channel.
+	UserId    string
+	ChannelId string
+
+	// SampleRate is the fraction of the posts that are traced, between 0 and 1. Zero traces
+	// every post.
+	SampleRate float64
+
+	IncludeText bool
+}
+
+// traces reports whether the post should be traced.
+func (s *TraceSettings) traces(post *model.Post) bool {
+	if s == nil || !s.Enabled {
+		return false
+	}
+	if (s.UserId != "" && post.UserId != s.UserId) || (s.ChannelId != "" && post.ChannelId != s.ChannelId) {
+		return false
+	}
+	return s.SampleRate <= 0 || s.SampleRate >= 1 || traceSample() < s.SampleRate
+}
+
+// postTrace explains how a post was linked. Its methods do nothing on a nil trace, so that
+// the pipeline can record to it without checking whether the post is traced.
+type postTrace struct {
+	UserId        string
+	ChannelId     string
+	MessageLength int
+
+	// Links lists every configured link, and why it was not tried.
+	Links []*linkTrace
+
+	Nodes []*nodeTrace
+
+	Outcome string
+
+	includeText bool
+	bare        map[*regexpMatcher]*regexp.Regexp
+}
+
+type linkTrace struct {
+	Link    string
+	Skipped string `json:",omitempty"`
+}
+
+// nodeTrace is a node of the message that was visited: a run of text nodes, which the links
+// were tried on, or a node that was skipped.
+type nodeTrace struct {
+	Type       string
+	Start, End int             `json:",omitempty"`
+	Text       string          `json:",omitempty"`
+	Skipped    string          `json:",omitempty"`
+	Links      []*attemptTrace `json:",omitempty"`
+}
+
+// attemptTrace is a link tried on the text of a node.
+type attemptTrace struct {
+	Link    string
+	Matches []*matchTrace `json:",omitempty"`
+
+	// BoundaryMisses are the matches of the pattern of a link that has no matches, but
+	// matches without its boundaries.
+	BoundaryMisses []*matchTrace `json:",omitempty"`
+
+	// Discarded is set if the replacements changed the structure of the rest of the
+	// message, and Exhausted if the processing budget ran out before the link was tried.
+	Discarded bool `json:",omitempty"`
+	Exhausted bool `json:",omitempty"`
+}
+
+type matchTrace struct {
+	Text       string
+	Start, End int
+
+	// Before and After are the boundaries of the match: the characters around it.
+	Before, After string
+
+	Groups    map[string]string `json:",omitempty"`
+	Rejected  string            `json:",omitempty"`
+	Unaligned bool              `json:",omitempty"`
+}
+
+// newPostTrace returns a trace for the post if it should be traced, or nil.
+func (p *Plugin) newPostTrace(post *model.Post) *postTrace {
+	settings := p.getConfiguration().Trace
+	if !settings.traces(post) {
+		return nil
+	}
+	return &postTrace{
+		UserId:        post.UserId,
+		ChannelId:     post.ChannelId,
+		MessageLength: len(post.Message),
+		includeText:   settings.IncludeText,
+		bare:          make(map[*regexpMatcher]*regexp.Regexp),
+	}
+}
+
+// redact returns the text if the trace includes texts, or its length otherwise.
+func (t *postTrace) redact(text string) string {
+	if t.includeText {
+		return text
+	}
+	return fmt.Sprintf("[%d bytes]", len(text))
+}
+
+// rejected returns why the match was rejected. Unless the trace includes texts, the capture
+// is redacted from the reason, which validators quote.
+func (t *postTrace) rejected(m *Match) string {
+	if t.includeText || m.rejection == nil {
+		return m.Rejected
+	}
+	return t.redactError(m.rejection).Error()
+}
+
+// redactError returns the error with its Capture parameters redacted. Errors that are not
+// localized are redacted whole.
+func (t *postTrace) redactError(err error) error {
+	e, ok := err.(*localizedError)
+	if !ok {
+		return errors.New(t.redact(err.Error()))
+	}
+	params := make(map[string]interface{}, len(e.params))
+	for name, value := range e.params {
+		switch v := value.(type) {
+		case error:
+			value = t.redactError(v)
+		case string:
+			if name == "Capture" {
+				value = t.redact(v)
+			}
+		}
+		params[name] = value
+	}
+	return &localizedError{id: e.id, params: params}
+}
+
+// links records which of the links were tried, the others failing their conditions.
+func (t *postTrace) links(links, applicable []*AutoLinker) {
+	if t == nil {
+		return
+	}
+	isApplicable := make(map[*AutoLinker]bool, len(applicable))
+	for _, l := range applicable {
+		isApplicable[l] = true
+	}
+	for _, l := range links {
+		lt := &linkTrace{Link: l.link.DisplayName()}
+		if !isApplicable[l] {
+			lt.Skipped = "conditions"
+		}
+		t.Links = append(t.Links, lt)
+	}
+}
+
+// skipNode records a node left alone for the reason.
+func (t *postTrace) skipNode(node interface{}, reason string) {
+	if t == nil {
+		return
+	}
+	t.Nodes = append(t.Nodes, &nodeTrace{Type: traceNodeType(node), Skipped: reason})
+}
+
+// textNode records a run of text nodes, and returns the trace to record the links tried on
+// it to.
+func (t *postTrace) textNode(start, end int, text string) *nodeTrace {
+	if t == nil {
+		return nil
+	}
+	n := &nodeTrace{Type: "Text", Start: start, End: end, Text: t.redact(text)}
+	t.Nodes = append(t.Nodes, n)
+	return n
+}
+
+// outcome records the outcome of the post.
+func (t *postTrace) outcome(outcome string) {
+	if t == nil {
+		return
+	}
+	t.Outcome = outcome
+}
+
+// finish logs the trace. Posts without an outcome were left unchanged.
+func (t *postTrace) finish() {
+	if t == nil {
+		return
+	}
+	if t.Outcome == "" {
+		t.Outcome = traceUnchanged
+	}
+	mlog.Info("Traced linking a post",
+		mlog.String("user_id", t.UserId),
+		mlog.String("channel_id", t.ChannelId),
+		mlog.String("outcome", t.Outcome),
+		mlog.Any("trace", t))
+}
+
+// attempt returns the trace of the link tried on the text: every match, including those
+// rejected or not aligned with escapes, and the matches made.
+func (t *postTrace) attempt(l *AutoLinker, source *escapedText, made []*Match) *attemptTrace {
+	if t == nil {
+		return nil
+	}
+
+	a := &attemptTrace{Link: l.link.DisplayName()}
+	isMade := make(map[int]bool, len(made))
+	for _, m := range made {
+		isMade[m.Start] = true
+	}
+	for _, m := range l.Explain(source.text) {
+		mt := t.match(source.text, m.Start, m.End)
+		mt.Rejected = t.rejected(m)
+		mt.Unaligned = m.Rejected == "" && !isMade[m.Start]
+		if len(m.Captures) > 0 {
+			mt.Groups = make(map[string]string, len(m.Captures))
+			for name, value := range m.Captures {
+				mt.Groups[name] = t.redact(value)
+			}
+		}
+		a.Matches = append(a.Matches, mt)
+	}
+
+	if len(a.Matches) == 0 {
+		if bare := t.bareExpression(l); bare != nil {
+			for _, loc := range bare.FindAllStringIndex(source.text, maxTraceBoundaryMisses) {
+				if loc[0] < loc[1] {
+					a.BoundaryMisses = append(a.BoundaryMisses, t.match(source.text, loc[0], loc[1]))
+				}
+			}
+		}
+	}
+	return a
+}
+
+// exhaustedAttempt returns the trace of a link that was not tried on the text because the
+// processing budget ran out.
+func (t *postTrace) exhaustedAttempt(l *AutoLinker) *attemptTrace {
+	if t == nil {
+		return nil
+	}
+	return &attemptTrace{Link: l.link.DisplayName(), Exhausted: true}
+}
+
+// match returns the trace of the text from start to end, with its boundaries.
+func (t *postTrace) match(text string, start, end int) *matchTrace {
+	mt := &matchTrace{Text: t.redact(text[start:end]), Start: start, End: end, Before: "start", After: "end"}
+	if r, size := utf8.DecodeLastRuneInString(text[:start]); size > 0 {
+		mt.Before = t.boundary(r)
+	}
+	if r, size := utf8.DecodeRuneInString(text[end:]); size > 0 {
+		mt.After = t.boundary(r)
+	}
+	return mt
+}
+
+// boundary describes a character around a match: the character if the trace includes
+// texts, or its class otherwise.
+func (t *postTrace) boundary(r rune) string {
+	if t.includeText {
+		return string(r)
+	}
+	switch {
+	case unicode.IsSpace(r):
+		return "space"
+	case unicode.IsPunct(r):
+		return "punctuation"
+	case unicode.IsSymbol(r):
+		return "symbol"
+	case unicode.IsDigit(r):
+		return "digit"
+	case unicode.IsLetter(r):
+		return "letter"
+	}
+	return "other"
+}
+
+// bareExpression returns the pattern of the link without its boundaries, or nil if the link
+// has none.
+func (t *postTrace) bareExpression(l *AutoLinker) *regexp.Regexp {
+	m, ok := l.matcher.(*regexpMatcher)
+	if !ok || (l.link.DisableNonWordPrefix && l.link.DisableNonWordSuffix) {
+		return nil
+	}
+	if m.unicodeBoundaries {
+		return m.pattern
+	}
+	if bare, ok := t.bare[m]; ok {
+		return bare
+	}
+	bare, err := regexp.Compile(m.expression)
+	if err != nil {
+		bare = nil
+	}
+	t.bare[m] = bare
+	return bare
+}
+
+// skip records that the node was left alone for the reason.
+func (n *nodeTrace) skip(reason string) {
+	if n == nil {
+		return
+	}
+	n.Skipped = reason
+}
+
+// tried records the links tried on the node.
+func (n *nodeTrace) tried(attempts []*attemptTrace) {
+	if n == nil {
+		return
+	}
+	n.Links = attempts
+}
+
+// discard records that the replacements of the link were left out.
+func (a *attemptTrace) discard() {
+	if a == nil {
+		return
+	}
+	a.Discarded = true
+}
+
+// traceNodeType returns the name of the type of a markdown node.
+func traceNodeType(node interface{}) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*markdown.")
+}
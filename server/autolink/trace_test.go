@@ -0,0 +1,157 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceSettings(t *testing.T) {
+	defer func(sample func() float64) { traceSample = sample }(traceSample)
+	traceSample = func() float64 { return 0.5 }
+
+	post := &model.Post{UserId: "user", ChannelId: "channel"}
+	for name, tc := range map[string]struct {
+		settings *TraceSettings
+		expected bool
+	}{
+		"unset":         {nil, false},
+		"disabled":      {&TraceSettings{UserId: "user"}, false},
+		"global":        {&TraceSettings{Enabled: true}, true},
+		"user":          {&TraceSettings{Enabled: true, UserId: "user"}, true},
+		"other user":    {&TraceSettings{Enabled: true, UserId: "other"}, false},
+		"channel":       {&TraceSettings{Enabled: true, ChannelId: "channel"}, true},
+		"other channel": {&TraceSettings{Enabled: true, UserId: "user", ChannelId: "other"}, false},
+		"sampled":       {&TraceSettings{Enabled: true, SampleRate: 0.6}, true},
+		"not sampled":   {&TraceSettings{Enabled: true, SampleRate: 0.4}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.settings.traces(post))
+		})
+	}
+}
+
+// captureTraces collects the traces logged until the returned function is called.
+func captureTraces() (*[]*postTrace, func()) {
+	var traces []*postTrace
+	info := mlog.Info
+	mlog.Info = func(msg string, fields ...mlog.Field) {
+		for _, f := range fields {
+			if trace, ok := f.Interface.(*postTrace); ok && f.Key == "trace" {
+				traces = append(traces, trace)
+			}
+		}
+	}
+	return &traces, func() { mlog.Info = info }
+}
+
+func TestTrace(t *testing.T) {
+	config := func(includeText bool) string {
+		text := "false"
+		if includeText {
+			text = "true"
+		}
+		return `{
+			"Trace": {"Enabled": true, "UserId": "user", "IncludeText": ` + text + `},
+			"Links": [{
+				"Name": "jira",
+				"Pattern": "(?P<id>MM-\\d+)",
+				"Template": "[${id}](https://mattermost.atlassian.net/browse/${id})"
+			}, {
+				"Name": "github",
+				"Pattern": "#(?P<issue>\\d+)",
+				"Template": "[#${issue}](https://github.com/mattermost/mattermost-server/issues/${issue})",
+				"Conditions": {"MessagePattern": "\\bPR\\b"}
+			}]
+		}`
+	}
+
+	traces, restore := captureTraces()
+	defer restore()
+
+	p := setupGoldenPlugin(t, config(false))
+	p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user", ChannelId: "channel", Message: "See MM-1, `MM-2` and [MM-3](https://example.com) xMM-4"})
+	p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "other", ChannelId: "channel", Message: "See MM-1"})
+	require.Len(t, *traces, 1)
+
+	trace := (*traces)[0]
+	assert.Equal(t, traceLinked, trace.Outcome)
+	assert.Equal(t, []*linkTrace{{Link: "jira"}, {Link: "github", Skipped: "conditions"}}, trace.Links)
+
+	var types []string
+	for _, n := range trace.Nodes {
+		types = append(types, n.Type+" "+n.Skipped)
+	}
+	assert.Equal(t, []string{"Text ", "CodeSpan code", "Text ", "InlineLink link", "Text "}, types)
+
+	first := trace.Nodes[0]
+	assert.Equal(t, "[10 bytes]", first.Text)
+	require.Len(t, first.Links, 1)
+	assert.Equal(t, []*matchTrace{{
+		Text:   "[4 bytes]",
+		Start:  4,
+		End:    8,
+		Before: "space",
+		After:  "punctuation",
+		Groups: map[string]string{"id": "[4 bytes]"},
+	}}, first.Links[0].Matches)
+
+	// The last text only matches without its boundaries.
+	last := trace.Nodes[4].Links[0]
+	assert.Empty(t, last.Matches)
+	require.Len(t, last.BoundaryMisses, 1)
+	assert.Equal(t, "letter", last.BoundaryMisses[0].Before)
+	assert.Equal(t, "end", last.BoundaryMisses[0].After)
+
+	*traces = nil
+	p = setupGoldenPlugin(t, config(true))
+	p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user", ChannelId: "channel", Message: "Nothing (MM-1)"})
+	require.Len(t, *traces, 1)
+	trace = (*traces)[0]
+	assert.Equal(t, traceUnchanged, trace.Outcome)
+	require.Len(t, trace.Nodes, 1)
+	assert.Equal(t, "Nothing (MM-1)", trace.Nodes[0].Text)
+	require.Len(t, trace.Nodes[0].Links[0].BoundaryMisses, 1)
+	assert.Equal(t, &matchTrace{Text: "MM-1", Start: 9, End: 13, Before: "(", After: ")"}, trace.Nodes[0].Links[0].BoundaryMisses[0])
+}
+
+func TestTraceRejected(t *testing.T) {
+	config := func(includeText bool) string {
+		text := "false"
+		if includeText {
+			text = "true"
+		}
+		return `{
+			"Trace": {"Enabled": true, "IncludeText": ` + text + `},
+			"Links": [{
+				"Name": "jira",
+				"Pattern": "MM-(?P<id>\\d+)",
+				"Template": "[MM-${id}](https://mattermost.atlassian.net/browse/MM-${id})",
+				"Validators": {"id": {"Max": 99}}
+			}]
+		}`
+	}
+
+	traces, restore := captureTraces()
+	defer restore()
+
+	// The reason quotes the capture, which is redacted like the rest of the text.
+	p := setupGoldenPlugin(t, config(false))
+	p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user", Message: "See MM-12345"})
+	require.Len(t, *traces, 1)
+	matches := (*traces)[0].Nodes[0].Links[0].Matches
+	require.Len(t, matches, 1)
+	assert.Equal(t, `id: "[5 bytes]" is greater than 99`, matches[0].Rejected)
+
+	*traces = nil
+	p = setupGoldenPlugin(t, config(true))
+	p.MessageWillBePosted(&plugin.Context{}, &model.Post{UserId: "user", Message: "See MM-12345"})
+	require.Len(t, *traces, 1)
+	matches = (*traces)[0].Nodes[0].Links[0].Matches
+	require.Len(t, matches, 1)
+	assert.Equal(t, `id: "12345" is greater than 99`, matches[0].Rejected)
+}
@@ -0,0 +1,124 @@
+package autolink
+
+var translationsDE = map[string]string{
+	// Commands
+	"autolink.command.not_admin": "Nur Systemadministratoren können `/autolink` verwenden.",
+	"autolink.command.help": "Verfügbare Befehle:\n" +
+		"* `/autolink revert post <post-id>` - stellt die ursprüngliche Nachricht eines verlinkten Beitrags wieder her\n" +
+		"* `/autolink revert link <name> <from> [<to>]` - stellt die ursprünglichen Nachrichten der von einem Link geänderten Beiträge wieder her\n" +
+		"* `/autolink test <message>` - zeigt, wie eine Nachricht verlinkt würde, ohne sie zu senden\n" +
+		"* `/autolink history` - listet die Revisionen der Links auf, wenn sie im KV-Speicher liegen\n" +
+		"* `/autolink rollback <revision>` - aktiviert die Links einer Revision wieder\n" +
+		"* `/autolink status` - zeigt die Revision der Links, die jeder Knoten des Clusters verwendet\n" +
+		"* `/autolink perf` - zeigt, wie lange das Verlinken von Nachrichten auf diesem Knoten dauert, nach Link",
+	"autolink.command.invalid_time": "ungültige Zeitangabe \"{{.Value}}\"",
+
+	"autolink.command.revert.usage": "Verwendung: `/autolink revert post <post-id>` oder `/autolink revert link <name> <from> [<to>]`. " +
+		"Zeiten werden als `2006-01-02` oder `2006-01-02T15:04:05Z07:00` angegeben.",
+	"autolink.command.revert.post_failed":   "Beitrag {{.PostId}} konnte nicht wiederhergestellt werden: {{.Error}}.",
+	"autolink.command.revert.post_done":     "Beitrag {{.PostId}} wurde wiederhergestellt.",
+	"autolink.command.revert.invalid_time":  "Beiträge konnten nicht wiederhergestellt werden: {{.Error}}. {{.Usage}}",
+	"autolink.command.revert.link_failed":   "Die von {{.Link}} geänderten Beiträge konnten nicht wiederhergestellt werden: {{.Error}}.",
+	"autolink.command.revert.link_done":     "{{.Posts}} von {{.Link}} geänderte Beiträge wurden wiederhergestellt.",
+	"autolink.command.revert.indexed_since": "Vor {{.Since}} erstellte Beiträge sind nicht im Index der verlinkten Beiträge und wurden nicht wiederhergestellt.",
+	"autolink.command.revert.not_indexed":   "Es wurden noch keine verlinkten Beiträge indiziert.",
+	"autolink.revert.not_rewritten":         "der Beitrag wurde nicht von Autolink geändert",
+	"autolink.revert.edited":                "der Beitrag wurde bearbeitet, nachdem er verlinkt wurde",
+
+	"autolink.command.test.usage":     "Verwendung: `/autolink test <message>`.",
+	"autolink.command.test.exhausted": "Das Verarbeitungsbudget wurde beim Anwenden von **{{.Link}}** aufgebraucht, die Nachricht würde unverändert gesendet.",
+	"autolink.command.test.posted_as": "Die Nachricht würde so gesendet:\n```\n{{.Message}}\n```",
+	"autolink.command.test.compiled":  "* **{{.Link}}**: das Muster `{{.Pattern}}` wird zu `{{.Expression}}` übersetzt.",
+	"autolink.command.test.skipped":   "* **{{.Link}}**: übersprungen, die Bedingungen sind nicht erfüllt.",
+	"autolink.command.test.linked":    "* **{{.Link}}**: `{{.Text}}` wurde mit {{.URL}} verlinkt",
+	"autolink.command.test.rejected":  "* **{{.Link}}**: `{{.Text}}` wurde nicht verlinkt, {{.Reason}}.",
+	"autolink.command.test.unsafe":    "* **{{.Link}}**: einige Treffer wurden nicht verlinkt, die Ersetzung würde die Struktur der restlichen Nachricht verändern.",
+
+	// Matches
+	"autolink.match.no_template":          "keine Vorlage für die gefundenen Gruppen",
+	"autolink.match.rejected":             "{{.Group}}: {{.Reason}}",
+	"autolink.validator.not_a_number":     "\"{{.Capture}}\" ist keine Zahl",
+	"autolink.validator.less_than":        "\"{{.Capture}}\" ist kleiner als {{.Min}}",
+	"autolink.validator.greater_than":     "\"{{.Capture}}\" ist größer als {{.Max}}",
+	"autolink.validator.shorter_than":     "\"{{.Capture}}\" ist kürzer als {{.MinLength}} Zeichen",
+	"autolink.validator.longer_than":      "\"{{.Capture}}\" ist länger als {{.MaxLength}} Zeichen",
+	"autolink.validator.not_allowed":      "\"{{.Capture}}\" ist kein erlaubter Wert",
+	"autolink.validator.luhn":             "\"{{.Capture}}\" besteht die Luhn-Prüfung nicht",
+	"autolink.validator.isbn":             "\"{{.Capture}}\" ist keine gültige ISBN",
+	"autolink.validator.unknown_group":    "Validators verweist auf \"{{.Group}}\", das keine benannte Gruppe des Musters ist",
+	"autolink.validator.min_max":          "der Validator für die Gruppe \"{{.Group}}\" hat ein Min größer als Max",
+	"autolink.validator.length":           "der Validator für die Gruppe \"{{.Group}}\" hat eine MinLength größer als MaxLength",
+	"autolink.validator.unknown_checksum": "der Validator für die Gruppe \"{{.Group}}\" hat die unbekannte Prüfsumme \"{{.Checksum}}\", erwartet wird luhn oder isbn",
+
+	// Link validation
+	"autolink.link.pattern_or_template_empty": "Muster oder Vorlage ist leer",
+	"autolink.link.webhook_url":               "WebhookURL muss eine absolute http- oder https-URL sein",
+	"autolink.link.unknown_type":              "unbekannter Link-Typ \"{{.Type}}\"",
+	"autolink.link.unknown_boundaries":        "unbekannte Grenzen \"{{.Boundaries}}\", erwartet wird whitespace oder unicode",
+	"autolink.link.templates_unknown_group":   "Templates verweist auf \"{{.Group}}\", das keine benannte Gruppe des Musters ist",
+	"autolink.link.group_template_empty":      "die Vorlage für die Gruppe \"{{.Group}}\" ist leer",
+	"autolink.link.undefined_variable":        "nicht definierte Variable \"{{.Name}}\"",
+	"autolink.link.pattern_length":            "das Muster ist {{.Length}} Zeichen lang, die Grenze liegt bei {{.Limit}}",
+	"autolink.link.capture_groups":            "das Muster hat {{.Groups}} Gruppen, die Grenze liegt bei {{.Limit}}",
+	"autolink.link.program_size":              "das übersetzte Muster hat {{.Instructions}} Anweisungen, die Grenze liegt bei {{.Limit}}",
+	"autolink.link.lookup_template_empty":     "die Lookup-Vorlage ist leer",
+	"autolink.link.lookup_url":                "die Lookup-URL muss eine absolute http- oder https-URL sein",
+	"autolink.link.channel_type":              "unbekannter Kanaltyp \"{{.Type}}\", erwartet wird O, P, D oder G",
+	"autolink.link.condition_pattern_length":  "{{.Field}} ist {{.Length}} Zeichen lang, die Grenze liegt bei {{.Limit}}",
+	"autolink.link.condition_pattern":         "{{.Field}}: {{.Error}}",
+	"autolink.link.dictionary_name":           "Wörterbuch-Links müssen einen Namen haben",
+	"autolink.link.dictionary_empty":          "das Wörterbuch ist leer",
+	"autolink.link.dictionary_empty_term":     "das Wörterbuch enthält einen leeren Begriff",
+	"autolink.link.dictionary_term_length":    "das Wörterbuch enthält einen Begriff, der länger als die Grenze für Muster ist",
+	"autolink.link.simple_escape":             "das Muster endet mit einem unvollständigen Escape",
+	"autolink.link.simple_unclosed":           "der Platzhalter an Position {{.Position}} ist nicht geschlossen",
+	"autolink.link.simple_unexpected_brace":   "unerwartetes } an Position {{.Position}}",
+	"autolink.link.simple_name":               "ungültiger Platzhaltername \"{{.Name}}\"",
+	"autolink.link.simple_duplicate":          "der Platzhalter \"{{.Name}}\" wird doppelt verwendet",
+	"autolink.link.simple_class":              "unbekannte Platzhalterklasse \"{{.Class}}\", erwartet wird digits, letters, word oder text",
+
+	// Configuration
+	"autolink.configuration.unsupported_version": "Konfigurationsversion {{.Version}} wird nicht unterstützt, die neueste unterstützte Version ist {{.Current}}",
+	"autolink.configuration.migration_failed":    "Konfigurationsmigration {{.Name}} fehlgeschlagen: {{.Error}}",
+
+	// Link history
+	"autolink.command.history.not_kv":     "Der Verlauf der Links wird nur gespeichert, wenn `Storage` in der Plugin-Konfiguration auf `kv` gesetzt ist.",
+	"autolink.command.history.failed":     "Der Verlauf der Links konnte nicht geladen werden: {{.Error}}.",
+	"autolink.command.history.empty":      "Es wurde noch keine Revision der Links gespeichert.",
+	"autolink.command.history.header":     "Revisionen der Links, neueste zuerst:",
+	"autolink.command.history.revision":   "* **{{.Revision}}**{{.Active}} {{.Time}} von {{.User}}: {{.Change}}, {{.Links}} Links",
+	"autolink.command.history.active":     " (aktiv)",
+	"autolink.command.history.system":     "dem Plugin",
+	"autolink.history.import":             "aus der Konfiguration importiert",
+	"autolink.history.create":             "{{.Link}} erstellt",
+	"autolink.history.update":             "{{.Link}} geändert",
+	"autolink.history.delete":             "{{.Link}} gelöscht",
+	"autolink.history.rollback":           "auf Revision {{.From}} zurückgesetzt",
+	"autolink.command.rollback.usage":     "Verwendung: `/autolink rollback <revision>`. `/autolink history` listet die Revisionen auf.",
+	"autolink.command.rollback.failed":    "Zurücksetzen auf Revision {{.Revision}} fehlgeschlagen: {{.Error}}.",
+	"autolink.command.rollback.done":      "Auf Revision {{.From}} zurückgesetzt, die Links sind jetzt auf Revision {{.Revision}}.",
+	"autolink.storage.revision_not_found": "Revision {{.Revision}} wurde nicht gefunden",
+	"autolink.storage.unknown":            "unbekannter Speicher \"{{.Storage}}\", erwartet wird config oder kv",
+	"autolink.storage.concurrent_change":  "die Links wurden gleichzeitig auf einem anderen Knoten geändert, prüfen Sie `/autolink history` und versuchen Sie es erneut",
+
+	// Cluster status
+	"autolink.command.status.not_kv":    "Die Links liegen in der Serverkonfiguration, die jeder Knoten des Clusters lädt. Dieser Knoten verwendet {{.Links}} Links.",
+	"autolink.command.status.failed":    "Der Status des Clusters konnte nicht geladen werden: {{.Error}}.",
+	"autolink.command.status.header":    "Die neueste Revision der Links ist **{{.Revision}}**. Knoten des Clusters:",
+	"autolink.command.status.node":      "* **{{.Node}}**{{.Current}}: Revision {{.Revision}}{{.State}}, aktiviert {{.Applied}}, zuletzt geprüft {{.Checked}}",
+	"autolink.command.status.this_node": " (dieser Knoten)",
+	"autolink.command.status.behind":    ", veraltet",
+	"autolink.command.status.unseen":    ", länger nicht gemeldet",
+
+	// Performance
+	"autolink.command.perf.empty":   "Seit {{.Since}} wurden auf diesem Knoten keine Nachrichten verlinkt.",
+	"autolink.command.perf.header":  "Zeit für das Verlinken von Nachrichten auf diesem Knoten seit {{.Since}}. Links, die für eine Nachricht länger als {{.Threshold}} brauchen, werden protokolliert.",
+	"autolink.command.perf.columns": "| Link | Nachrichten | p50 | p95 | p99 | Max |\n|:-----|------:|----:|----:|----:|----:|",
+	"autolink.command.perf.row":     "| {{.Link}} | {{.Count}} | {{.P50}} | {{.P95}} | {{.P99}} | {{.Max}} |",
+	"autolink.command.perf.posts":   "*Alle Links*",
+
+	// Overlapping links
+	"autolink.overlap.shadowed": "\"{{.Link}}\" wird nie angewendet, \"{{.Other}}\" steht davor und verlinkt seine Treffer immer, etwa \"{{.Text}}\"",
+	"autolink.overlap.conflict": "\"{{.Link}}\" und \"{{.Other}}\" treffen beide \"{{.Text}}\" und verlinken es auf verschiedene URLs, {{.URL}} und {{.OtherURL}}",
+	"autolink.overlap.overlap":  "\"{{.Link}}\" und \"{{.Other}}\" treffen beide \"{{.Text}}\"",
+}
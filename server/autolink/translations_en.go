@@ -0,0 +1,124 @@
+package autolink
+
+var translationsEN = map[string]string{
+	// Commands
+	"autolink.command.not_admin": "Only system administrators can use `/autolink`.",
+	"autolink.command.help": "Available commands:\n" +
+		"* `/autolink revert post <post-id>` - restore the original message of a linked post\n" +
+		"* `/autolink revert link <name> <from> [<to>]` - restore the original messages of the posts changed by a link\n" +
+		"* `/autolink test <message>` - show how a message would be linked, without posting it\n" +
+		"* `/autolink history` - list the revisions of the links, when they are kept in the KV store\n" +
+		"* `/autolink rollback <revision>` - make the links of a revision active again\n" +
+		"* `/autolink status` - show the revision of the links every node of the cluster runs\n" +
+		"* `/autolink perf` - show how long linking posts takes on this node, by link",
+	"autolink.command.invalid_time": "invalid time \"{{.Value}}\"",
+
+	"autolink.command.revert.usage": "Usage: `/autolink revert post <post-id>` or `/autolink revert link <name> <from> [<to>]`. " +
+		"Times are given as `2006-01-02` or `2006-01-02T15:04:05Z07:00`.",
+	"autolink.command.revert.post_failed":   "Could not revert post {{.PostId}}: {{.Error}}.",
+	"autolink.command.revert.post_done":     "Reverted post {{.PostId}}.",
+	"autolink.command.revert.invalid_time":  "Could not revert posts: {{.Error}}. {{.Usage}}",
+	"autolink.command.revert.link_failed":   "Could not revert posts changed by {{.Link}}: {{.Error}}.",
+	"autolink.command.revert.link_done":     "Reverted {{.Posts}} posts changed by {{.Link}}.",
+	"autolink.command.revert.indexed_since": "Posts created before {{.Since}} are not in the index of linked posts, and were not reverted.",
+	"autolink.command.revert.not_indexed":   "No linked posts were indexed yet.",
+	"autolink.revert.not_rewritten":         "post was not changed by autolink",
+	"autolink.revert.edited":                "post was edited after it was linked",
+
+	"autolink.command.test.usage":     "Usage: `/autolink test <message>`.",
+	"autolink.command.test.exhausted": "The processing budget ran out while applying **{{.Link}}**, the message would be posted unchanged.",
+	"autolink.command.test.posted_as": "The message would be posted as:\n```\n{{.Message}}\n```",
+	"autolink.command.test.compiled":  "* **{{.Link}}**: pattern `{{.Pattern}}` compiles to `{{.Expression}}`.",
+	"autolink.command.test.skipped":   "* **{{.Link}}**: skipped, its conditions do not hold.",
+	"autolink.command.test.linked":    "* **{{.Link}}**: linked `{{.Text}}` to {{.URL}}",
+	"autolink.command.test.rejected":  "* **{{.Link}}**: did not link `{{.Text}}`, {{.Reason}}.",
+	"autolink.command.test.unsafe":    "* **{{.Link}}**: some matches were not linked, the replacement would change the structure of the rest of the message.",
+
+	// Matches
+	"autolink.match.no_template":          "no template for the groups that matched",
+	"autolink.match.rejected":             "{{.Group}}: {{.Reason}}",
+	"autolink.validator.not_a_number":     "\"{{.Capture}}\" is not a number",
+	"autolink.validator.less_than":        "\"{{.Capture}}\" is less than {{.Min}}",
+	"autolink.validator.greater_than":     "\"{{.Capture}}\" is greater than {{.Max}}",
+	"autolink.validator.shorter_than":     "\"{{.Capture}}\" is shorter than {{.MinLength}} characters",
+	"autolink.validator.longer_than":      "\"{{.Capture}}\" is longer than {{.MaxLength}} characters",
+	"autolink.validator.not_allowed":      "\"{{.Capture}}\" is not an allowed value",
+	"autolink.validator.luhn":             "\"{{.Capture}}\" fails the Luhn check",
+	"autolink.validator.isbn":             "\"{{.Capture}}\" is not a valid ISBN",
+	"autolink.validator.unknown_group":    "Validators refers to \"{{.Group}}\", which is not a named group of the pattern",
+	"autolink.validator.min_max":          "validator for group \"{{.Group}}\" has Min greater than Max",
+	"autolink.validator.length":           "validator for group \"{{.Group}}\" has MinLength greater than MaxLength",
+	"autolink.validator.unknown_checksum": "validator for group \"{{.Group}}\" has unknown checksum \"{{.Checksum}}\", expected luhn or isbn",
+
+	// Link validation
+	"autolink.link.pattern_or_template_empty": "Pattern or template was empty",
+	"autolink.link.webhook_url":               "WebhookURL must be an absolute http or https URL",
+	"autolink.link.unknown_type":              "unknown link type \"{{.Type}}\"",
+	"autolink.link.unknown_boundaries":        "unknown boundaries \"{{.Boundaries}}\", expected whitespace or unicode",
+	"autolink.link.templates_unknown_group":   "Templates refers to \"{{.Group}}\", which is not a named group of the pattern",
+	"autolink.link.group_template_empty":      "template for group \"{{.Group}}\" was empty",
+	"autolink.link.undefined_variable":        "undefined variable \"{{.Name}}\"",
+	"autolink.link.pattern_length":            "pattern is {{.Length}} characters long, the limit is {{.Limit}}",
+	"autolink.link.capture_groups":            "pattern has {{.Groups}} capture groups, the limit is {{.Limit}}",
+	"autolink.link.program_size":              "compiled pattern has {{.Instructions}} instructions, the limit is {{.Limit}}",
+	"autolink.link.lookup_template_empty":     "Lookup template was empty",
+	"autolink.link.lookup_url":                "Lookup URL must be an absolute http or https URL",
+	"autolink.link.channel_type":              "unknown channel type \"{{.Type}}\", expected O, P, D or G",
+	"autolink.link.condition_pattern_length":  "{{.Field}} is {{.Length}} characters long, the limit is {{.Limit}}",
+	"autolink.link.condition_pattern":         "{{.Field}}: {{.Error}}",
+	"autolink.link.dictionary_name":           "dictionary links must have a name",
+	"autolink.link.dictionary_empty":          "Dictionary was empty",
+	"autolink.link.dictionary_empty_term":     "Dictionary has an empty term",
+	"autolink.link.dictionary_term_length":    "Dictionary has a term longer than the pattern length limit",
+	"autolink.link.simple_escape":             "pattern ends with an unfinished escape",
+	"autolink.link.simple_unclosed":           "placeholder at {{.Position}} is not closed",
+	"autolink.link.simple_unexpected_brace":   "unexpected } at {{.Position}}",
+	"autolink.link.simple_name":               "invalid placeholder name \"{{.Name}}\"",
+	"autolink.link.simple_duplicate":          "placeholder \"{{.Name}}\" is used twice",
+	"autolink.link.simple_class":              "unknown placeholder class \"{{.Class}}\", expected digits, letters, word or text",
+
+	// Configuration
+	"autolink.configuration.unsupported_version": "configuration version {{.Version}} is not supported, the latest supported version is {{.Current}}",
+	"autolink.configuration.migration_failed":    "configuration migration {{.Name}} failed: {{.Error}}",
+
+	// Link history
+	"autolink.command.history.not_kv":     "Link history is only kept when `Storage` is set to `kv` in the plugin configuration.",
+	"autolink.command.history.failed":     "Could not load the history of the links: {{.Error}}.",
+	"autolink.command.history.empty":      "No revision of the links was saved yet.",
+	"autolink.command.history.header":     "Revisions of the links, newest first:",
+	"autolink.command.history.revision":   "* **{{.Revision}}**{{.Active}} {{.Time}} by {{.User}}: {{.Change}}, {{.Links}} links",
+	"autolink.command.history.active":     " (active)",
+	"autolink.command.history.system":     "the plugin",
+	"autolink.history.import":             "imported from the configuration",
+	"autolink.history.create":             "created {{.Link}}",
+	"autolink.history.update":             "updated {{.Link}}",
+	"autolink.history.delete":             "deleted {{.Link}}",
+	"autolink.history.rollback":           "rolled back to revision {{.From}}",
+	"autolink.command.rollback.usage":     "Usage: `/autolink rollback <revision>`. `/autolink history` lists the revisions.",
+	"autolink.command.rollback.failed":    "Could not roll back to revision {{.Revision}}: {{.Error}}.",
+	"autolink.command.rollback.done":      "Rolled back to revision {{.From}}, the links are now at revision {{.Revision}}.",
+	"autolink.storage.revision_not_found": "revision {{.Revision}} was not found",
+	"autolink.storage.unknown":            "unknown storage \"{{.Storage}}\", expected config or kv",
+	"autolink.storage.concurrent_change":  "the links were changed on another node at the same time, check `/autolink history` and try again",
+
+	// Cluster status
+	"autolink.command.status.not_kv":    "The links are kept in the server configuration, which every node of the cluster loads. This node runs {{.Links}} links.",
+	"autolink.command.status.failed":    "Could not load the status of the cluster: {{.Error}}.",
+	"autolink.command.status.header":    "The latest revision of the links is **{{.Revision}}**. Nodes of the cluster:",
+	"autolink.command.status.node":      "* **{{.Node}}**{{.Current}}: revision {{.Revision}}{{.State}}, applied {{.Applied}}, last checked {{.Checked}}",
+	"autolink.command.status.this_node": " (this node)",
+	"autolink.command.status.behind":    ", behind",
+	"autolink.command.status.unseen":    ", not seen recently",
+
+	// Performance
+	"autolink.command.perf.empty":   "No posts were linked on this node since {{.Since}}.",
+	"autolink.command.perf.header":  "Time spent linking posts on this node since {{.Since}}. Links taking longer than {{.Threshold}} on a post are logged.",
+	"autolink.command.perf.columns": "| Link | Posts | p50 | p95 | p99 | Max |\n|:-----|------:|----:|----:|----:|----:|",
+	"autolink.command.perf.row":     "| {{.Link}} | {{.Count}} | {{.P50}} | {{.P95}} | {{.P99}} | {{.Max}} |",
+	"autolink.command.perf.posts":   "*All links*",
+
+	// Overlapping links
+	"autolink.overlap.shadowed": "\"{{.Link}}\" never applies, \"{{.Other}}\" comes first and always links its matches, such as \"{{.Text}}\"",
+	"autolink.overlap.conflict": "\"{{.Link}}\" and \"{{.Other}}\" both match \"{{.Text}}\" and link it to different URLs, {{.URL}} and {{.OtherURL}}",
+	"autolink.overlap.overlap":  "\"{{.Link}}\" and \"{{.Other}}\" both match \"{{.Text}}\"",
+}
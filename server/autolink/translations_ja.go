@@ -0,0 +1,124 @@
+package autolink
+
+var translationsJA = map[string]string{
+	// Commands
+	"autolink.command.not_admin": "`/autolink` を使用できるのはシステム管理者のみです。",
+	"autolink.command.help": "利用できるコマンド:\n" +
+		"* `/autolink revert post <post-id>` - リンクされた投稿の元のメッセージを復元します\n" +
+		"* `/autolink revert link <name> <from> [<to>]` - リンクによって変更された投稿の元のメッセージを復元します\n" +
+		"* `/autolink test <message>` - メッセージを投稿せずに、どのようにリンクされるかを表示します\n" +
+		"* `/autolink history` - KV ストアに保存されているリンクのリビジョンを一覧表示します\n" +
+		"* `/autolink rollback <revision>` - リビジョンのリンクを再び有効にします\n" +
+		"* `/autolink status` - クラスターの各ノードが使用しているリンクのリビジョンを表示します\n" +
+		"* `/autolink perf` - このノードで投稿のリンクにかかる時間をリンクごとに表示します",
+	"autolink.command.invalid_time": "無効な時刻 \"{{.Value}}\"",
+
+	"autolink.command.revert.usage": "使い方: `/autolink revert post <post-id>` または `/autolink revert link <name> <from> [<to>]`。" +
+		"時刻は `2006-01-02` または `2006-01-02T15:04:05Z07:00` の形式で指定します。",
+	"autolink.command.revert.post_failed":   "投稿 {{.PostId}} を復元できませんでした: {{.Error}}。",
+	"autolink.command.revert.post_done":     "投稿 {{.PostId}} を復元しました。",
+	"autolink.command.revert.invalid_time":  "投稿を復元できませんでした: {{.Error}}。{{.Usage}}",
+	"autolink.command.revert.link_failed":   "{{.Link}} によって変更された投稿を復元できませんでした: {{.Error}}。",
+	"autolink.command.revert.link_done":     "{{.Link}} によって変更された投稿を {{.Posts}} 件復元しました。",
+	"autolink.command.revert.indexed_since": "{{.Since}} より前に作成された投稿はリンクされた投稿のインデックスにないため、復元されていません。",
+	"autolink.command.revert.not_indexed":   "リンクされた投稿はまだインデックスされていません。",
+	"autolink.revert.not_rewritten":         "この投稿は autolink によって変更されていません",
+	"autolink.revert.edited":                "この投稿はリンクされた後に編集されています",
+
+	"autolink.command.test.usage":     "使い方: `/autolink test <message>`。",
+	"autolink.command.test.exhausted": "**{{.Link}}** の適用中に処理の上限に達したため、メッセージは変更されずに投稿されます。",
+	"autolink.command.test.posted_as": "メッセージは次のように投稿されます:\n```\n{{.Message}}\n```",
+	"autolink.command.test.compiled":  "* **{{.Link}}**: パターン `{{.Pattern}}` は `{{.Expression}}` に変換されます。",
+	"autolink.command.test.skipped":   "* **{{.Link}}**: 条件を満たさないためスキップしました。",
+	"autolink.command.test.linked":    "* **{{.Link}}**: `{{.Text}}` を {{.URL}} にリンクしました",
+	"autolink.command.test.rejected":  "* **{{.Link}}**: `{{.Text}}` はリンクされませんでした。{{.Reason}}。",
+	"autolink.command.test.unsafe":    "* **{{.Link}}**: 置換によってメッセージの他の部分の構造が変わるため、一部の一致はリンクされませんでした。",
+
+	// Matches
+	"autolink.match.no_template":          "一致したグループのテンプレートがありません",
+	"autolink.match.rejected":             "{{.Group}}: {{.Reason}}",
+	"autolink.validator.not_a_number":     "\"{{.Capture}}\" は数値ではありません",
+	"autolink.validator.less_than":        "\"{{.Capture}}\" は {{.Min}} より小さい値です",
+	"autolink.validator.greater_than":     "\"{{.Capture}}\" は {{.Max}} より大きい値です",
+	"autolink.validator.shorter_than":     "\"{{.Capture}}\" は {{.MinLength}} 文字より短いです",
+	"autolink.validator.longer_than":      "\"{{.Capture}}\" は {{.MaxLength}} 文字より長いです",
+	"autolink.validator.not_allowed":      "\"{{.Capture}}\" は許可された値ではありません",
+	"autolink.validator.luhn":             "\"{{.Capture}}\" は Luhn チェックに失敗しました",
+	"autolink.validator.isbn":             "\"{{.Capture}}\" は有効な ISBN ではありません",
+	"autolink.validator.unknown_group":    "Validators が参照している \"{{.Group}}\" はパターンの名前付きグループではありません",
+	"autolink.validator.min_max":          "グループ \"{{.Group}}\" のバリデーターの Min が Max より大きいです",
+	"autolink.validator.length":           "グループ \"{{.Group}}\" のバリデーターの MinLength が MaxLength より大きいです",
+	"autolink.validator.unknown_checksum": "グループ \"{{.Group}}\" のバリデーターのチェックサム \"{{.Checksum}}\" は不明です。luhn または isbn を指定してください",
+
+	// Link validation
+	"autolink.link.pattern_or_template_empty": "パターンまたはテンプレートが空です",
+	"autolink.link.webhook_url":               "WebhookURL は http または https の絶対 URL でなければなりません",
+	"autolink.link.unknown_type":              "不明なリンクの種類 \"{{.Type}}\"",
+	"autolink.link.unknown_boundaries":        "不明な境界 \"{{.Boundaries}}\"。whitespace または unicode を指定してください",
+	"autolink.link.templates_unknown_group":   "Templates が参照している \"{{.Group}}\" はパターンの名前付きグループではありません",
+	"autolink.link.group_template_empty":      "グループ \"{{.Group}}\" のテンプレートが空です",
+	"autolink.link.undefined_variable":        "未定義の変数 \"{{.Name}}\"",
+	"autolink.link.pattern_length":            "パターンの長さは {{.Length}} 文字で、上限は {{.Limit}} 文字です",
+	"autolink.link.capture_groups":            "パターンには {{.Groups}} 個のグループがあり、上限は {{.Limit}} 個です",
+	"autolink.link.program_size":              "コンパイルされたパターンの命令数は {{.Instructions}} で、上限は {{.Limit}} です",
+	"autolink.link.lookup_template_empty":     "Lookup のテンプレートが空です",
+	"autolink.link.lookup_url":                "Lookup の URL は http または https の絶対 URL でなければなりません",
+	"autolink.link.channel_type":              "不明なチャンネルの種類 \"{{.Type}}\"。O、P、D、G のいずれかを指定してください",
+	"autolink.link.condition_pattern_length":  "{{.Field}} の長さは {{.Length}} 文字で、上限は {{.Limit}} 文字です",
+	"autolink.link.condition_pattern":         "{{.Field}}: {{.Error}}",
+	"autolink.link.dictionary_name":           "辞書リンクには名前が必要です",
+	"autolink.link.dictionary_empty":          "辞書が空です",
+	"autolink.link.dictionary_empty_term":     "辞書に空の用語があります",
+	"autolink.link.dictionary_term_length":    "辞書にパターンの長さの上限を超える用語があります",
+	"autolink.link.simple_escape":             "パターンが未完了のエスケープで終わっています",
+	"autolink.link.simple_unclosed":           "位置 {{.Position}} のプレースホルダーが閉じられていません",
+	"autolink.link.simple_unexpected_brace":   "位置 {{.Position}} に予期しない } があります",
+	"autolink.link.simple_name":               "無効なプレースホルダー名 \"{{.Name}}\"",
+	"autolink.link.simple_duplicate":          "プレースホルダー \"{{.Name}}\" が 2 回使用されています",
+	"autolink.link.simple_class":              "不明なプレースホルダーのクラス \"{{.Class}}\"。digits、letters、word、text のいずれかを指定してください",
+
+	// Configuration
+	"autolink.configuration.unsupported_version": "設定バージョン {{.Version}} はサポートされていません。サポートされている最新のバージョンは {{.Current}} です",
+	"autolink.configuration.migration_failed":    "設定の移行 {{.Name}} に失敗しました: {{.Error}}",
+
+	// Link history
+	"autolink.command.history.not_kv":     "リンクの履歴は、プラグイン設定の `Storage` が `kv` の場合にのみ保存されます。",
+	"autolink.command.history.failed":     "リンクの履歴を読み込めませんでした: {{.Error}}。",
+	"autolink.command.history.empty":      "リンクのリビジョンはまだ保存されていません。",
+	"autolink.command.history.header":     "リンクのリビジョン (新しい順):",
+	"autolink.command.history.revision":   "* **{{.Revision}}**{{.Active}} {{.Time}} {{.User}}: {{.Change}}、リンク {{.Links}} 件",
+	"autolink.command.history.active":     " (有効)",
+	"autolink.command.history.system":     "プラグイン",
+	"autolink.history.import":             "設定からインポート",
+	"autolink.history.create":             "{{.Link}} を作成",
+	"autolink.history.update":             "{{.Link}} を更新",
+	"autolink.history.delete":             "{{.Link}} を削除",
+	"autolink.history.rollback":           "リビジョン {{.From}} にロールバック",
+	"autolink.command.rollback.usage":     "使い方: `/autolink rollback <revision>`。リビジョンは `/autolink history` で確認できます。",
+	"autolink.command.rollback.failed":    "リビジョン {{.Revision}} にロールバックできませんでした: {{.Error}}。",
+	"autolink.command.rollback.done":      "リビジョン {{.From}} にロールバックしました。リンクは現在リビジョン {{.Revision}} です。",
+	"autolink.storage.revision_not_found": "リビジョン {{.Revision}} が見つかりません",
+	"autolink.storage.unknown":            "不明なストレージ \"{{.Storage}}\" です。config または kv を指定してください",
+	"autolink.storage.concurrent_change":  "リンクが別のノードで同時に変更されました。`/autolink history` を確認してもう一度お試しください",
+
+	// Cluster status
+	"autolink.command.status.not_kv":    "リンクはサーバー設定に保存されており、クラスターの各ノードが読み込みます。このノードはリンクを {{.Links}} 件使用しています。",
+	"autolink.command.status.failed":    "クラスターの状態を読み込めませんでした: {{.Error}}。",
+	"autolink.command.status.header":    "リンクの最新リビジョンは **{{.Revision}}** です。クラスターのノード:",
+	"autolink.command.status.node":      "* **{{.Node}}**{{.Current}}: リビジョン {{.Revision}}{{.State}}、有効化 {{.Applied}}、最終確認 {{.Checked}}",
+	"autolink.command.status.this_node": " (このノード)",
+	"autolink.command.status.behind":    "、古いリビジョン",
+	"autolink.command.status.unseen":    "、最近応答なし",
+
+	// Performance
+	"autolink.command.perf.empty":   "{{.Since}} 以降、このノードでリンクされた投稿はありません。",
+	"autolink.command.perf.header":  "{{.Since}} 以降、このノードで投稿のリンクにかかった時間です。1 件の投稿で {{.Threshold}} を超えたリンクはログに記録されます。",
+	"autolink.command.perf.columns": "| リンク | 投稿数 | p50 | p95 | p99 | 最大 |\n|:-----|------:|----:|----:|----:|----:|",
+	"autolink.command.perf.row":     "| {{.Link}} | {{.Count}} | {{.P50}} | {{.P95}} | {{.P99}} | {{.Max}} |",
+	"autolink.command.perf.posts":   "*すべてのリンク*",
+
+	// Overlapping links
+	"autolink.overlap.shadowed": "「{{.Link}}」は適用されません。先にある「{{.Other}}」が「{{.Text}}」などの一致を常にリンクします",
+	"autolink.overlap.conflict": "「{{.Link}}」と「{{.Other}}」はどちらも「{{.Text}}」に一致し、異なる URL ({{.URL}} と {{.OtherURL}}) にリンクします",
+	"autolink.overlap.overlap":  "「{{.Link}}」と「{{.Other}}」はどちらも「{{.Text}}」に一致します",
+}
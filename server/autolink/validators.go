@@ -0,0 +1,156 @@
+package autolink
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	checksumLuhn = "luhn"
+	checksumISBN = "isbn"
+)
+
+// compileValidators checks that every validator of the link is well formed and refers to a
+// group of the matcher.
+func compileValidators(link *Link, matcher Matcher) (map[string]*Validator, error) {
+	validators := make(map[string]*Validator, len(link.Validators))
+	for name, v := range link.Validators {
+		if !hasGroup(matcher, name) {
+			return nil, newLocalizedError("autolink.validator.unknown_group", map[string]interface{}{"Group": name})
+		}
+		if v == nil {
+			continue
+		}
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return nil, newLocalizedError("autolink.validator.min_max", map[string]interface{}{"Group": name})
+		}
+		if v.MaxLength > 0 && v.MinLength > v.MaxLength {
+			return nil, newLocalizedError("autolink.validator.length", map[string]interface{}{"Group": name})
+		}
+		switch v.Checksum {
+		case "", checksumLuhn, checksumISBN:
+		default:
+			return nil, newLocalizedError("autolink.validator.unknown_checksum", map[string]interface{}{"Group": name, "Checksum": v.Checksum})
+		}
+		validators[name] = v
+	}
+	return validators, nil
+}
+
+// validate returns why the capture fails the validator, or nil if it passes.
+func (v *Validator) validate(capture string) error {
+	if v.Min != nil || v.Max != nil {
+		n, err := strconv.ParseInt(capture, 10, 64)
+		if err != nil {
+			return newLocalizedError("autolink.validator.not_a_number", map[string]interface{}{"Capture": capture})
+		}
+		if v.Min != nil && n < *v.Min {
+			return newLocalizedError("autolink.validator.less_than", map[string]interface{}{"Capture": capture, "Min": *v.Min})
+		}
+		if v.Max != nil && n > *v.Max {
+			return newLocalizedError("autolink.validator.greater_than", map[string]interface{}{"Capture": capture, "Max": *v.Max})
+		}
+	}
+
+	length := utf8.RuneCountInString(capture)
+	if v.MinLength > 0 && length < v.MinLength {
+		return newLocalizedError("autolink.validator.shorter_than", map[string]interface{}{"Capture": capture, "MinLength": v.MinLength})
+	}
+	if v.MaxLength > 0 && length > v.MaxLength {
+		return newLocalizedError("autolink.validator.longer_than", map[string]interface{}{"Capture": capture, "MaxLength": v.MaxLength})
+	}
+
+	if len(v.Values) > 0 {
+		allowed := false
+		for _, value := range v.Values {
+			if capture == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return newLocalizedError("autolink.validator.not_allowed", map[string]interface{}{"Capture": capture})
+		}
+	}
+
+	switch v.Checksum {
+	case checksumLuhn:
+		if !validLuhn(capture) {
+			return newLocalizedError("autolink.validator.luhn", map[string]interface{}{"Capture": capture})
+		}
+	case checksumISBN:
+		if !validISBN(capture) {
+			return newLocalizedError("autolink.validator.isbn", map[string]interface{}{"Capture": capture})
+		}
+	}
+
+	return nil
+}
+
+// stripSeparators removes the spaces and hyphens commonly used to group digits.
+func stripSeparators(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+func validLuhn(s string) bool {
+	s = stripSeparators(s)
+	if len(s) < 2 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validISBN checks the check digit of an ISBN-10 or ISBN-13.
+func validISBN(s string) bool {
+	s = stripSeparators(s)
+
+	switch len(s) {
+	case 10:
+		sum := 0
+		for i := 0; i < 10; i++ {
+			var d int
+			switch {
+			case s[i] >= '0' && s[i] <= '9':
+				d = int(s[i] - '0')
+			case i == 9 && (s[i] == 'X' || s[i] == 'x'):
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i := 0; i < 13; i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+			d := int(s[i] - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
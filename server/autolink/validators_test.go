@@ -0,0 +1,80 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func TestValidator(t *testing.T) {
+	for _, tt := range []struct {
+		Name      string
+		Validator Validator
+		Capture   string
+		Expected  string
+	}{
+		{"in range", Validator{Min: int64Ptr(1999), Max: int64Ptr(2100)}, "2018", ""},
+		{"below range", Validator{Min: int64Ptr(1999)}, "0000", `"0000" is less than 1999`},
+		{"above range", Validator{Max: int64Ptr(9999)}, "10000", `"10000" is greater than 9999`},
+		{"not a number", Validator{Min: int64Ptr(1)}, "x", `"x" is not a number`},
+		{"too short", Validator{MinLength: 4}, "1", `"1" is shorter than 4 characters`},
+		{"too long", Validator{MaxLength: 2}, "123", `"123" is longer than 2 characters`},
+		{"allowed value", Validator{Values: []string{"bug", "story"}}, "bug", ""},
+		{"unknown value", Validator{Values: []string{"bug", "story"}}, "epic", `"epic" is not an allowed value`},
+		{"luhn", Validator{Checksum: checksumLuhn}, "7992 7398 713", ""},
+		{"bad luhn", Validator{Checksum: checksumLuhn}, "79927398710", `"79927398710" fails the Luhn check`},
+		{"isbn 10", Validator{Checksum: checksumISBN}, "0-306-40615-2", ""},
+		{"isbn 10 with X", Validator{Checksum: checksumISBN}, "0-8044-2957-X", ""},
+		{"isbn 13", Validator{Checksum: checksumISBN}, "978-0-306-40615-7", ""},
+		{"bad isbn", Validator{Checksum: checksumISBN}, "978-0-306-40615-6", `"978-0-306-40615-6" is not a valid ISBN`},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := tt.Validator.validate(tt.Capture)
+			if tt.Expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.Expected)
+			}
+		})
+	}
+}
+
+func TestAutolinkValidators(t *testing.T) {
+	al, err := NewAutoLinker(&Link{
+		Pattern:  "CVE-(?P<year>\\d{4})-(?P<id>\\d+)",
+		Template: "[CVE-${year}-${id}](https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-${year}-${id})",
+		Validators: map[string]*Validator{
+			"year": {Min: int64Ptr(1999)},
+			"id":   {MinLength: 4},
+		},
+	}, nil)
+	require.Nil(t, err)
+
+	message := "CVE-0000-1 and CVE-2018-1000001"
+	assert.Equal(t, "CVE-0000-1 and [CVE-2018-1000001](https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2018-1000001)", al.Replace(message))
+
+	explained := al.Explain(message)
+	require.Len(t, explained, 2)
+	assert.Equal(t, `year: "0000" is less than 1999`, explained[0].Rejected)
+	assert.Equal(t, "", explained[1].Rejected)
+	assert.Len(t, al.Matches(message), 1)
+
+	_, err = NewAutoLinker(&Link{
+		Pattern:    "RFC (?P<rfc>\\d+)",
+		Template:   "RFC ${rfc}",
+		Validators: map[string]*Validator{"number": {Min: int64Ptr(1)}},
+	}, nil)
+	assert.EqualError(t, err, `Validators refers to "number", which is not a named group of the pattern`)
+
+	_, err = NewAutoLinker(&Link{
+		Pattern:    "RFC (?P<rfc>\\d+)",
+		Template:   "RFC ${rfc}",
+		Validators: map[string]*Validator{"rfc": {Checksum: "crc"}},
+	}, nil)
+	assert.EqualError(t, err, `validator for group "rfc" has unknown checksum "crc", expected luhn or isbn`)
+}
@@ -0,0 +1,48 @@
+package autolink
+
+import (
+	"regexp"
+	"strings"
+)
+
+var variablePattern = regexp.MustCompile(`\$\{var:([A-Za-z0-9_.\-]+)\}`)
+
+// resolveVariables replaces the ${var:name} references in a template with the values of the
+// configuration's variables. Referencing an undefined variable is an error.
+func resolveVariables(template string, conf *Configuration) (string, error) {
+	var undefined []string
+	resolved := variablePattern.ReplaceAllStringFunc(template, func(reference string) string {
+		name := variablePattern.FindStringSubmatch(reference)[1]
+		var value string
+		var ok bool
+		if conf != nil {
+			value, ok = conf.Variables[name]
+		}
+		if !ok {
+			undefined = append(undefined, name)
+			return reference
+		}
+		// Escape $ so that the value is not expanded as a group of the pattern.
+		return strings.Replace(value, "$", "$$", -1)
+	})
+
+	if len(undefined) > 0 {
+		return "", newLocalizedError("autolink.link.undefined_variable", map[string]interface{}{"Name": undefined[0]})
+	}
+	return resolved, nil
+}
+
+// resolveLookupVariables returns a copy of the lookup with the variables of its URL and
+// template resolved.
+func resolveLookupVariables(lookup *Lookup, conf *Configuration) (*Lookup, error) {
+	resolved := *lookup
+
+	var err error
+	if resolved.URL, err = resolveVariables(lookup.URL, conf); err != nil {
+		return nil, err
+	}
+	if resolved.Template, err = resolveVariables(lookup.Template, conf); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,41 @@
+package autolink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariables(t *testing.T) {
+	conf := &Configuration{
+		Variables: map[string]string{
+			"jira":  "https://mattermost.atlassian.net/browse/",
+			"price": "$5",
+		},
+	}
+
+	l, err := NewAutoLinker(&Link{
+		Pattern:  "(MM)(-)(?P<jira_id>\\d+)",
+		Template: "[MM-${jira_id}](${var:jira}MM-${jira_id})",
+	}, conf)
+	require.Nil(t, err)
+	assert.Equal(t, "See [MM-123](https://mattermost.atlassian.net/browse/MM-123)", l.Replace("See MM-123"))
+
+	l, err = NewAutoLinker(&Link{Pattern: "(?P<item>coffee)", Template: "${item} for ${var:price}"}, conf)
+	require.Nil(t, err)
+	assert.Equal(t, "coffee for $5", l.Replace("coffee"))
+
+	_, err = NewAutoLinker(&Link{Pattern: "(MM)", Template: "${var:confluence}/MM"}, conf)
+	assert.EqualError(t, err, `undefined variable "confluence"`)
+
+	_, err = NewAutoLinker(&Link{Pattern: "(MM)", Template: "${var:jira}MM"}, nil)
+	assert.EqualError(t, err, `undefined variable "jira"`)
+
+	_, err = NewAutoLinker(&Link{
+		Pattern:  "(MM)",
+		Template: "MM",
+		Lookup:   &Lookup{URL: "${var:api}/issue", Template: "MM"},
+	}, conf)
+	assert.EqualError(t, err, `undefined variable "api"`)
+}
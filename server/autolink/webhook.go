@@ -0,0 +1,361 @@
+package autolink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/mlog"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const (
+	webhookEventLinkMatched = "link_matched"
+
+	// webhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request body, keyed
+	// with the link's WebhookSecret.
+	webhookSignatureHeader = "X-Autolink-Signature"
+
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookWorkers     = 4
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookBackoff     = time.Second
+	defaultWebhookTimeout     = 10 * time.Second
+
+	// heldWebhookExpiry is how long the matches of a post are held for webhook events until
+	// it is saved. They are dropped after that if the post is never saved.
+	heldWebhookExpiry = time.Minute
+)
+
+// WebhookEvent is the payload posted to a link's webhook after a post matching the link
+// was saved.
+type WebhookEvent struct {
+	Event       string
+	Link        string
+	Text        string
+	Captures    map[string]string `json:",omitempty"`
+	URL         string            `json:",omitempty"`
+	PostId      string
+	Permalink   string
+	ChannelId   string
+	ChannelName string `json:",omitempty"`
+	TeamId      string `json:",omitempty"`
+	UserId      string
+	Username    string `json:",omitempty"`
+	CreateAt    int64
+}
+
+// webhookDelivery is a webhook event waiting to be sent.
+type webhookDelivery struct {
+	url    string
+	secret string
+	event  *WebhookEvent
+}
+
+// webhookDispatcher sends webhook events from a bounded queue, retrying failed deliveries
+// with exponential backoff. Events are dropped when the queue is full.
+type webhookDispatcher struct {
+	client      *http.Client
+	queue       chan *webhookDelivery
+	maxAttempts int
+	backoff     time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	// held holds the matches of the posts about to be saved, by heldWebhookKey, in the
+	// order they were made. Identical posts share a key.
+	heldLock  sync.Mutex
+	held      map[string][]*heldWebhookEvents
+	heldPosts int
+}
+
+type heldWebhookEvents struct {
+	entities []*Entity
+	heldAt   time.Time
+}
+
+func newWebhookDispatcher(queueSize int) *webhookDispatcher {
+	return &webhookDispatcher{
+		client:      &http.Client{Timeout: defaultWebhookTimeout},
+		queue:       make(chan *webhookDelivery, queueSize),
+		maxAttempts: defaultWebhookMaxAttempts,
+		backoff:     defaultWebhookBackoff,
+		stop:        make(chan struct{}),
+		held:        make(map[string][]*heldWebhookEvents),
+	}
+}
+
+// start launches the workers sending the queued events.
+func (d *webhookDispatcher) start(workers int) {
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case delivery := <-d.queue:
+					d.deliver(delivery)
+				case <-d.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// close stops the workers. Events still queued are dropped.
+func (d *webhookDispatcher) close() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// enqueue queues an event without blocking and reports whether there was room for it.
+func (d *webhookDispatcher) enqueue(delivery *webhookDelivery) bool {
+	select {
+	case d.queue <- delivery:
+		return true
+	default:
+		return false
+	}
+}
+
+// hold keeps the matches of a post until it is saved, and reports whether there was room
+// for them. There is room for as many posts as events can be queued.
+func (d *webhookDispatcher) hold(key string, entities []*Entity) bool {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	now := time.Now()
+	for k, posts := range d.held {
+		kept := posts[:0]
+		for _, held := range posts {
+			if now.Sub(held.heldAt) <= heldWebhookExpiry {
+				kept = append(kept, held)
+			}
+		}
+		d.heldPosts -= len(posts) - len(kept)
+		if len(kept) == 0 {
+			delete(d.held, k)
+		} else {
+			d.held[k] = kept
+		}
+	}
+	if d.heldPosts >= cap(d.queue) {
+		return false
+	}
+	d.held[key] = append(d.held[key], &heldWebhookEvents{entities: entities, heldAt: now})
+	d.heldPosts++
+	return true
+}
+
+// release returns the matches held the longest for the key, or nil if there are none.
+func (d *webhookDispatcher) release(key string) []*Entity {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	posts := d.held[key]
+	if len(posts) == 0 {
+		return nil
+	}
+	if len(posts) == 1 {
+		delete(d.held, key)
+	} else {
+		d.held[key] = posts[1:]
+	}
+	d.heldPosts--
+	return posts[0].entities
+}
+
+// heldWebhookKey identifies the matches held for a post by what the post is saved with, so
+// that the saved post finds them without the plugin adding anything to it. The message is
+// the one the post is saved with, once linked.
+func heldWebhookKey(post *model.Post) string {
+	sum := sha256.Sum256([]byte(post.UserId + "\x00" + post.ChannelId + "\x00" + post.RootId + "\x00" + post.Message))
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *webhookDispatcher) deliver(delivery *webhookDelivery) {
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		mlog.Error("Failed to encode webhook event: "+err.Error(), mlog.String("link", delivery.event.Link))
+		return
+	}
+
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(delivery, body)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= d.maxAttempts {
+			mlog.Warn("Failed to deliver webhook event: "+err.Error(),
+				mlog.String("link", delivery.event.Link),
+				mlog.String("post_id", delivery.event.PostId),
+				mlog.Int("attempts", attempt))
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// send posts the event once and reports whether a failed delivery should be retried.
+func (d *webhookDispatcher) send(delivery *webhookDelivery, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, delivery.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if delivery.secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookBody(delivery.secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hookedLinks returns the links that have a webhook, by name.
+func (p *Plugin) hookedLinks() map[string]*Link {
+	hooked := make(map[string]*Link)
+	for _, l := range p.links.Load().([]*AutoLinker) {
+		if l.link.WebhookURL != "" {
+			hooked[l.link.DisplayName()] = l.link
+		}
+	}
+	return hooked
+}
+
+// holdWebhookEvents keeps the matches of links with a webhook made in a post about to be
+// saved, for the events sent once it is. The events are not built from the entities in the
+// post props, which may be changed before the post is saved, and are truncated when long.
+// It is called with the message of the post linked.
+func (p *Plugin) holdWebhookEvents(post *model.Post, entities []*Entity) {
+	if p.webhooks == nil {
+		return
+	}
+
+	hooked := p.hookedLinks()
+	var held []*Entity
+	for _, e := range entities {
+		if hooked[e.Link] != nil {
+			held = append(held, e)
+		}
+	}
+	if len(held) == 0 {
+		return
+	}
+
+	if !p.webhooks.hold(heldWebhookKey(post), held) {
+		mlog.Warn("Too many posts waiting to be saved, dropping their webhook events",
+			mlog.String("user_id", post.UserId),
+			mlog.String("channel_id", post.ChannelId))
+	}
+}
+
+// sendWebhookEvents queues an event for every match held for a saved post whose link has a
+// webhook.
+func (p *Plugin) sendWebhookEvents(post *model.Post) {
+	if p.webhooks == nil {
+		return
+	}
+	entities := p.webhooks.release(heldWebhookKey(post))
+	if len(entities) == 0 {
+		return
+	}
+
+	hooked := p.hookedLinks()
+	var events []*webhookDelivery
+	for _, e := range entities {
+		link := hooked[e.Link]
+		if link == nil {
+			continue
+		}
+		events = append(events, &webhookDelivery{
+			url:    link.WebhookURL,
+			secret: link.WebhookSecret,
+			event: &WebhookEvent{
+				Event:     webhookEventLinkMatched,
+				Link:      e.Link,
+				Text:      e.Text,
+				Captures:  e.Captures,
+				URL:       e.URL,
+				PostId:    post.Id,
+				ChannelId: post.ChannelId,
+				UserId:    post.UserId,
+				CreateAt:  post.CreateAt,
+			},
+		})
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	channelName, teamID, permalink := p.postLocation(post)
+	username := ""
+	if user, appErr := p.API.GetUser(post.UserId); appErr == nil {
+		username = user.Username
+	}
+
+	for _, delivery := range events {
+		delivery.event.ChannelName = channelName
+		delivery.event.TeamId = teamID
+		delivery.event.Permalink = permalink
+		delivery.event.Username = username
+		if !p.webhooks.enqueue(delivery) {
+			mlog.Warn("Webhook queue is full, dropping event",
+				mlog.String("link", delivery.event.Link),
+				mlog.String("post_id", post.Id))
+		}
+	}
+}
+
+// postLocation returns the channel name, team and permalink of a post. Posts in direct and
+// group messages, which belong to no team, link through the redirect route.
+func (p *Plugin) postLocation(post *model.Post) (string, string, string) {
+	siteURL := ""
+	if config := p.API.GetConfig(); config != nil && config.ServiceSettings.SiteURL != nil {
+		siteURL = strings.TrimSuffix(*config.ServiceSettings.SiteURL, "/")
+	}
+
+	channelName, teamID, teamName := "", "", "_redirect"
+	if channel, appErr := p.API.GetChannel(post.ChannelId); appErr == nil {
+		channelName = channel.Name
+		teamID = channel.TeamId
+	}
+	if teamID != "" {
+		if team, appErr := p.API.GetTeam(teamID); appErr == nil {
+			teamName = team.Name
+		}
+	}
+
+	return channelName, teamID, fmt.Sprintf("%s/%s/pl/%s", siteURL, teamName, post.Id)
+}
@@ -0,0 +1,256 @@
+package autolink
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/model"
+	"github.com/mattermost/mattermost-server/plugin"
+	"github.com/mattermost/mattermost-server/plugin/plugintest"
+	"github.com/mattermost/mattermost-server/plugin/plugintest/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver is a local stand-in for the service receiving webhook events.
+type webhookReceiver struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+	received chan struct{}
+}
+
+func newWebhookReceiver(failures int) *webhookReceiver {
+	r := &webhookReceiver{failures: failures, received: make(chan struct{}, 100)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		if r.failures > 0 {
+			r.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		r.received <- struct{}{}
+	}))
+	return r
+}
+
+func (r *webhookReceiver) wait(t *testing.T) {
+	select {
+	case <-r.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook event")
+	}
+}
+
+// setupWebhookTest activates the plugin with a link sending events to the receiver.
+func setupWebhookTest(t *testing.T, receiver *webhookReceiver) *Plugin {
+	conf := Configuration{
+		Links: []*Link{{
+			Name:          "jira",
+			Pattern:       "(MM)(-)(?P<jira_id>\\d+)",
+			Template:      "[MM-${jira_id}](https://mattermost.atlassian.net/browse/MM-${jira_id})",
+			WebhookURL:    receiver.URL,
+			WebhookSecret: "secret",
+		}, {
+			Name:     "mattermost",
+			Pattern:  "(Mattermost)",
+			Template: "[Mattermost](https://mattermost.com)",
+		}},
+	}
+
+	siteURL := "https://chat.example.com"
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.AnythingOfType("*autolink.Configuration")).Return(func(dest interface{}) error {
+		*dest.(*Configuration) = conf
+		return nil
+	})
+	api.On("RegisterCommand", mock.AnythingOfType("*model.Command")).Return(nil)
+	api.On("GetConfig").Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: &siteURL}})
+	api.On("GetChannel", "channel").Return(&model.Channel{Id: "channel", Name: "town-square", TeamId: "team"}, nil)
+	api.On("GetTeam", "team").Return(&model.Team{Id: "team", Name: "core"}, nil)
+	api.On("GetUser", "user").Return(&model.User{Id: "user", Username: "alice"}, nil)
+	mockKVStore(api)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.OnConfigurationChange()
+	require.Nil(t, p.OnActivate())
+	p.webhooks.backoff = time.Millisecond
+	return p
+}
+
+func TestWebhookEvents(t *testing.T) {
+	receiver := newWebhookReceiver(2)
+	defer receiver.Close()
+
+	p := setupWebhookTest(t, receiver)
+	defer p.OnDeactivate()
+
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{
+		Message:   "Mattermost fixed MM-123",
+		ChannelId: "channel",
+		UserId:    "user",
+	})
+	post.Id = "post"
+	post.CreateAt = 1234
+	p.MessageHasBeenPosted(&plugin.Context{}, post)
+
+	receiver.wait(t)
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	require.Len(t, receiver.bodies, 3)
+
+	var event WebhookEvent
+	require.Nil(t, json.Unmarshal(receiver.bodies[2], &event))
+	assert.Equal(t, WebhookEvent{
+		Event:       webhookEventLinkMatched,
+		Link:        "jira",
+		Text:        "MM-123",
+		Captures:    map[string]string{"jira_id": "123"},
+		URL:         "https://mattermost.atlassian.net/browse/MM-123",
+		PostId:      "post",
+		Permalink:   "https://chat.example.com/core/pl/post",
+		ChannelId:   "channel",
+		ChannelName: "town-square",
+		TeamId:      "team",
+		UserId:      "user",
+		Username:    "alice",
+		CreateAt:    1234,
+	}, event)
+
+	req := receiver.requests[2]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "sha256="+signWebhookBody("secret", receiver.bodies[2]), req.Header.Get(webhookSignatureHeader))
+}
+
+func TestWebhookEventsForged(t *testing.T) {
+	receiver := newWebhookReceiver(0)
+	defer receiver.Close()
+
+	p := setupWebhookTest(t, receiver)
+	defer p.OnDeactivate()
+
+	// Entities a post was not given by the plugin send no events.
+	forged := &model.Post{Id: "forged", Message: "Nothing to link", ChannelId: "channel", UserId: "user"}
+	forged.AddProp(propEntities, []interface{}{map[string]interface{}{"Link": "jira", "Text": "MM-1"}})
+	p.MessageHasBeenPosted(&plugin.Context{}, forged)
+
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{
+		Message:   "Fixed MM-2",
+		ChannelId: "channel",
+		UserId:    "user",
+	})
+	post.Id = "post"
+
+	// The same post by another user, or in another channel, does not release the events.
+	other := *post
+	other.UserId = "other"
+	p.MessageHasBeenPosted(&plugin.Context{}, &other)
+
+	p.MessageHasBeenPosted(&plugin.Context{}, post)
+	p.MessageHasBeenPosted(&plugin.Context{}, post)
+
+	receiver.wait(t)
+	time.Sleep(50 * time.Millisecond)
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	require.Len(t, receiver.bodies, 1)
+	var event WebhookEvent
+	require.Nil(t, json.Unmarshal(receiver.bodies[0], &event))
+	assert.Equal(t, "MM-2", event.Text)
+	assert.Equal(t, "post", event.PostId)
+}
+
+func TestWebhookEventsHeldWithoutProps(t *testing.T) {
+	receiver := newWebhookReceiver(0)
+	defer receiver.Close()
+
+	p := setupWebhookTest(t, receiver)
+	defer p.OnDeactivate()
+
+	// Nothing is added to the post to find its matches once it is saved, since props are
+	// saved with the post and sent to every client.
+	post, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{
+		Message:   "Fixed MM-3",
+		ChannelId: "channel",
+		UserId:    "user",
+	})
+	for name := range post.Props {
+		assert.Contains(t, []string{propEntities, propLinks, propOriginalMessage}, name)
+	}
+
+	// Identical posts each send their own events.
+	again, _ := p.MessageWillBePosted(&plugin.Context{}, &model.Post{
+		Message:   "Fixed MM-3",
+		ChannelId: "channel",
+		UserId:    "user",
+	})
+	post.Id, again.Id = "first", "second"
+	p.MessageHasBeenPosted(&plugin.Context{}, post)
+	p.MessageHasBeenPosted(&plugin.Context{}, again)
+	receiver.wait(t)
+	receiver.wait(t)
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	var ids []string
+	for _, body := range receiver.bodies {
+		var event WebhookEvent
+		require.Nil(t, json.Unmarshal(body, &event))
+		ids = append(ids, event.PostId)
+	}
+	assert.ElementsMatch(t, []string{"first", "second"}, ids)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	receiver := newWebhookReceiver(10)
+	defer receiver.Close()
+
+	d := newWebhookDispatcher(1)
+	d.backoff = time.Millisecond
+	d.maxAttempts = 3
+
+	d.deliver(&webhookDelivery{url: receiver.URL, event: &WebhookEvent{Link: "jira"}})
+	assert.Len(t, receiver.requests, 3)
+	assert.Empty(t, receiver.requests[0].Header.Get(webhookSignatureHeader))
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	retry, err := d.send(&webhookDelivery{url: notFound.URL, event: &WebhookEvent{}}, []byte("{}"))
+	assert.False(t, retry)
+	assert.NotNil(t, err)
+}
+
+func TestWebhookQueueBounded(t *testing.T) {
+	d := newWebhookDispatcher(2)
+
+	assert.True(t, d.enqueue(&webhookDelivery{}))
+	assert.True(t, d.enqueue(&webhookDelivery{}))
+	assert.False(t, d.enqueue(&webhookDelivery{}))
+}
+
+func TestWebhookURLValidation(t *testing.T) {
+	for _, webhookURL := range []string{"ftp://example.com", "/relative", "http://"} {
+		_, err := NewAutoLinker(&Link{Pattern: "(a)", Template: "b", WebhookURL: webhookURL}, nil)
+		assert.NotNil(t, err, webhookURL)
+	}
+
+	_, err := NewAutoLinker(&Link{Pattern: "(a)", Template: "b", WebhookURL: "https://example.com/hook"}, nil)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mattermost/mattermost-server/mlog"
+
+	"github.com/mattermost/mattermost-plugin-autolink/server/autolink"
+)
+
+// main runs autolink-lint, which compiles and applies links with the plugin's own package.
+func main() {
+	// Until the server configures it, mlog writes to stdout, where it would mix with the
+	// report.
+	logToStderr := func(msg string, fields ...mlog.Field) {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	mlog.Debug, mlog.Info = func(string, ...mlog.Field) {}, func(string, ...mlog.Field) {}
+	mlog.Warn, mlog.Error, mlog.Critical = logToStderr, logToStderr, logToStderr
+
+	os.Exit(autolink.RunLint(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"github.com/mattermost/mattermost-server/plugin"
+
+	"github.com/mattermost/mattermost-plugin-autolink/server/autolink"
 )
 
 func main() {
-	plugin.ClientMain(&Plugin{})
+	plugin.ClientMain(&autolink.Plugin{})
 }